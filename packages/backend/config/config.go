@@ -3,6 +3,8 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // 環境變數名稱常量
@@ -12,7 +14,33 @@ const (
 	EnvLLMApiKey      = "LLM_API_KEY"
 	EnvLLMApiEndpoint = "LLM_API_ENDPOINT"
 	EnvLLMModel       = "LLM_MODEL"
+	EnvLLMProvider    = "LLM_PROVIDER"
+	EnvLLMTimeout     = "LLM_TIMEOUT"
+	EnvLLMFallbacks   = "LLM_FALLBACKS"
+	EnvLLMFailoverOn  = "LLM_FAILOVER_ON"
+	EnvLLMAllowed     = "LLM_ALLOWED_MODELS"
+	EnvWSTokenLimit   = "WS_SESSION_TOKEN_LIMIT"
+	EnvHistoryBudget  = "LLM_HISTORY_TOKEN_BUDGET"
+	EnvContextBudget  = "LLM_PAGE_CONTEXT_TOKENS"
+	EnvContextMode    = "LLM_PAGE_CONTEXT_STRATEGY"
+	EnvMapParallel    = "LLM_MAP_REDUCE_CONCURRENCY"
+	EnvPDFMaxSize     = "PDF_MAX_SIZE_MB"
+	EnvStoreBackend   = "STORE_BACKEND"
+	EnvStorePath      = "STORE_PATH"
 	EnvDebug          = "DEBUG"
+	EnvWSOrigins      = "WS_ALLOWED_ORIGINS"
+
+	// 頁面內容向量檢索
+	EnvEmbeddingProvider = "EMBEDDING_PROVIDER"
+	EnvEmbeddingModel    = "EMBEDDING_MODEL"
+	EnvEmbeddingAPIKey   = "EMBEDDING_API_KEY"
+	EnvEmbeddingEndpoint = "EMBEDDING_API_ENDPOINT"
+
+	// Azure OpenAI
+	EnvAzureOpenAIEndpoint   = "AZURE_OPENAI_ENDPOINT"
+	EnvAzureOpenAIDeployment = "AZURE_OPENAI_DEPLOYMENT"
+	EnvAzureOpenAIAPIVersion = "AZURE_OPENAI_API_VERSION"
+	EnvAzureOpenAIAPIShape   = "AZURE_OPENAI_API_SHAPE"
 )
 
 // Config 應用配置
@@ -22,21 +50,247 @@ type Config struct {
 	LLMApiKey      string
 	LLMApiEndpoint string
 	LLMModel       string
+	LLMProvider    string
+	LLMTimeout     time.Duration
+	LLMFallbacks   []ModelRef // 主要提供者失敗時依序嘗試
+	LLMFailoverOn  []string   // 觸發故障轉移的錯誤類別
+	LLMAllowed     []ModelRef // 允許請求選擇的模型（主要模型總是允許）
+	WSTokenLimit   int        // 每個 WebSocket 會話的 token 上限，0 表示不限制；HTTP 問答沒有會話，不受此限制
+	WSOrigins      []string   // 瀏覽器擴展以外允許連線 /api/ws 的來源，* 表示允許所有來源
+	HistoryBudget  int        // 壓縮歷史時逐字保留最近輪次的 token 預算
+	ContextBudget  int        // 放入提示詞的頁面內容 token 預算，0 表示使用提供者的默認值
+	ContextMode    string     // 頁面內容選取策略：relevance、embedding 或 sequential
+	MapParallel    int        // 分段摘要模式同時進行的 LLM 呼叫數
+	PDFMaxSize     int        // PDF 問答接受的檔案大小上限（位元組）
+	StoreBackend   string     // 對話記錄儲存後端：bolt 或 memory
+	StorePath      string     // BoltDB 資料庫檔案路徑
 	Debug          bool
+	AzureOpenAI    AzureOpenAIConfig
+	Embedding      EmbeddingConfig
+}
+
+// ModelRef 表示一個提供者與模型的組合，格式為 provider:model
+type ModelRef struct {
+	Provider string
+	Model    string
+}
+
+// AzureOpenAIConfig Azure OpenAI 專用配置
+type AzureOpenAIConfig struct {
+	Endpoint   string // 資源端點，例如 https://my-resource.openai.azure.com
+	Deployment string // 默認部署名稱，azure 提供者未指定模型時使用
+	APIVersion string // api-version 查詢參數
+	APIShape   string // responses 或 chat
+}
+
+// EmbeddingConfig 頁面內容向量檢索使用的嵌入模型配置
+type EmbeddingConfig struct {
+	Provider string // openai 或 ollama
+	Model    string
+	APIKey   string // 未設置時使用 <PROVIDER>_API_KEY
+	Endpoint string // 未設置時使用提供者的默認端點
+}
+
+// defaultEmbeddingModels 各嵌入提供者的默認模型
+var defaultEmbeddingModels = map[string]string{
+	"openai": "text-embedding-3-small",
+	"ollama": "nomic-embed-text",
+}
+
+// DefaultContextBudget 未設置 LLM_PAGE_CONTEXT_TOKENS 時的頁面內容 token 預算
+const DefaultContextBudget = 2000
+
+// defaultLLMModels 各 LLM 提供者的默認模型
+var defaultLLMModels = map[string]string{
+	"openai":      "gpt-4o-mini",
+	"openai-chat": "gpt-4o-mini",
+	"anthropic":   "claude-3-5-sonnet-latest",
+	"ollama":      "llama3.2-vision",
+	"llamacpp":    "local-model",
+	"gemini":      "gemini-2.0-flash",
+	"azure":       "gpt-4o-mini",
 }
 
 // LoadConfig 從環境變數載入配置
 func LoadConfig() Config {
 	port, _ := strconv.Atoi(getEnvOrDefault(EnvPort, "8080"))
+	provider := strings.ToLower(getEnvOrDefault(EnvLLMProvider, "openai"))
+	timeout, err := strconv.Atoi(getEnvOrDefault(EnvLLMTimeout, "60"))
+	if err != nil || timeout <= 0 {
+		timeout = 60
+	}
+
+	wsTokenLimit, _ := strconv.Atoi(os.Getenv(EnvWSTokenLimit))
+	historyBudget, err := strconv.Atoi(getEnvOrDefault(EnvHistoryBudget, "4000"))
+	if err != nil || historyBudget < 0 {
+		historyBudget = 4000
+	}
+	// 未設置時由提供者決定，長上下文的提供者可以放入更多頁面內容
+	contextBudget, err := strconv.Atoi(getEnvOrDefault(EnvContextBudget, "0"))
+	if err != nil || contextBudget < 0 {
+		contextBudget = 0
+	}
+
+	mapParallel, err := strconv.Atoi(getEnvOrDefault(EnvMapParallel, "4"))
+	if err != nil || mapParallel <= 0 {
+		mapParallel = 4
+	}
+
+	pdfMaxSize, err := strconv.Atoi(getEnvOrDefault(EnvPDFMaxSize, "20"))
+	if err != nil || pdfMaxSize <= 0 {
+		pdfMaxSize = 20
+	}
+
+	embeddingProvider := strings.ToLower(getEnvOrDefault(EnvEmbeddingProvider, "openai"))
 
 	return Config{
 		GinMode:        getEnvOrDefault(EnvGinMode, "debug"),
 		Port:           port,
 		LLMApiKey:      os.Getenv(EnvLLMApiKey),
-		LLMApiEndpoint: getEnvOrDefault(EnvLLMApiEndpoint, "https://api.openai.com/v1/responses"),
-		LLMModel:       getEnvOrDefault(EnvLLMModel, "gpt-4o-mini"),
+		LLMApiEndpoint: os.Getenv(EnvLLMApiEndpoint), // 未設置時由各提供者使用默認端點
+		LLMModel:       getEnvOrDefault(EnvLLMModel, DefaultLLMModel(provider)),
+		LLMProvider:    provider,
+		LLMTimeout:     time.Duration(timeout) * time.Second,
+		LLMFallbacks:   ParseModelRefs(os.Getenv(EnvLLMFallbacks)),
+		LLMFailoverOn:  splitList(getEnvOrDefault(EnvLLMFailoverOn, "429,5xx,timeout")),
+		LLMAllowed:     parseAllowedModels(os.Getenv(EnvLLMAllowed), provider),
+		WSTokenLimit:   wsTokenLimit,
+		WSOrigins:      splitList(os.Getenv(EnvWSOrigins)),
+		HistoryBudget:  historyBudget,
+		ContextBudget:  contextBudget,
+		ContextMode:    strings.ToLower(getEnvOrDefault(EnvContextMode, "relevance")),
+		MapParallel:    mapParallel,
+		PDFMaxSize:     pdfMaxSize * 1024 * 1024,
+		StoreBackend:   strings.ToLower(getEnvOrDefault(EnvStoreBackend, "bolt")),
+		StorePath:      getEnvOrDefault(EnvStorePath, "data/assistant.db"),
 		Debug:          os.Getenv(EnvDebug) == "true",
+		AzureOpenAI: AzureOpenAIConfig{
+			Endpoint:   os.Getenv(EnvAzureOpenAIEndpoint),
+			Deployment: os.Getenv(EnvAzureOpenAIDeployment),
+			APIVersion: os.Getenv(EnvAzureOpenAIAPIVersion),
+			APIShape:   getEnvOrDefault(EnvAzureOpenAIAPIShape, "chat"),
+		},
+		Embedding: EmbeddingConfig{
+			Provider: embeddingProvider,
+			Model:    getEnvOrDefault(EnvEmbeddingModel, defaultEmbeddingModels[embeddingProvider]),
+			APIKey:   os.Getenv(EnvEmbeddingAPIKey),
+			Endpoint: os.Getenv(EnvEmbeddingEndpoint),
+		},
+	}
+}
+
+// DefaultLLMModel 返回指定提供者的默認模型
+func DefaultLLMModel(provider string) string {
+	// Azure 以部署名稱選擇模型，配置了默認部署時以它作為默認模型
+	if provider == "azure" {
+		if deployment := os.Getenv(EnvAzureOpenAIDeployment); deployment != "" {
+			return deployment
+		}
+	}
+	if model, ok := defaultLLMModels[provider]; ok {
+		return model
+	}
+	return "gpt-4o-mini"
+}
+
+// ParseModelRef 解析 provider:model 格式，未指定模型時使用提供者默認模型
+func ParseModelRef(value string) ModelRef {
+	provider, model, _ := strings.Cut(strings.TrimSpace(value), ":")
+	provider = strings.ToLower(strings.TrimSpace(provider))
+	model = strings.TrimSpace(model)
+	if model == "" {
+		model = DefaultLLMModel(provider)
 	}
+	return ModelRef{Provider: provider, Model: model}
+}
+
+// ParseModelRefs 解析以逗號分隔的 provider:model 列表
+func ParseModelRefs(value string) []ModelRef {
+	refs := []ModelRef{}
+	for _, item := range splitList(value) {
+		refs = append(refs, ParseModelRef(item))
+	}
+	return refs
+}
+
+// ID 返回模型識別碼，主要提供者的模型只使用模型名稱
+func (r ModelRef) ID(primaryProvider string) string {
+	if r.Provider == primaryProvider {
+		return r.Model
+	}
+	return r.Provider + ":" + r.Model
+}
+
+// AllowedModels 返回允許請求選擇的模型，第一個是主要模型
+func (c Config) AllowedModels() []ModelRef {
+	primary := ModelRef{Provider: c.LLMProvider, Model: c.LLMModel}
+	refs := []ModelRef{primary}
+	for _, ref := range c.LLMAllowed {
+		if ref != primary {
+			refs = append(refs, ref)
+		}
+	}
+	return refs
+}
+
+// parseAllowedModels 解析模型允許列表，未指定提供者的項目屬於主要提供者
+func parseAllowedModels(value, primaryProvider string) []ModelRef {
+	refs := []ModelRef{}
+	for _, item := range splitList(value) {
+		if !strings.Contains(item, ":") {
+			refs = append(refs, ModelRef{Provider: primaryProvider, Model: item})
+			continue
+		}
+		refs = append(refs, ParseModelRef(item))
+	}
+	return refs
+}
+
+// ProviderCredentials 返回提供者的 API 密鑰和端點
+// 優先使用 <PROVIDER>_API_KEY / <PROVIDER>_API_ENDPOINT，
+// 主要提供者則回退到 LLM_API_KEY / LLM_API_ENDPOINT
+func (c Config) ProviderCredentials(provider string) (apiKey, endpoint string) {
+	apiKey = os.Getenv(ProviderKeyEnv(provider))
+	endpoint = os.Getenv(providerEnvPrefix(provider) + "_API_ENDPOINT")
+	if provider == c.LLMProvider {
+		if apiKey == "" {
+			apiKey = c.LLMApiKey
+		}
+		if endpoint == "" {
+			endpoint = c.LLMApiEndpoint
+		}
+	}
+	return apiKey, endpoint
+}
+
+// browserExtensionSchemes 瀏覽器擴展頁面的 Origin scheme
+var browserExtensionSchemes = []string{"chrome-extension://", "moz-extension://", "safari-web-extension://"}
+
+// OriginAllowed 判斷瀏覽器的 Origin 是否允許連線 /api/ws
+// 瀏覽器擴展總是允許，其他網站必須列在 WS_ALLOWED_ORIGINS 中（默認為空），避免任意網頁開啟長連線消耗 LLM 額度；
+// HTTP API 的 CORS 不受此限制
+func (c Config) OriginAllowed(origin string) bool {
+	for _, scheme := range browserExtensionSchemes {
+		if strings.HasPrefix(origin, scheme) {
+			return true
+		}
+	}
+	for _, allowed := range c.WSOrigins {
+		if allowed == "*" || strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// ProviderKeyEnv 返回提供者的 API 金鑰環境變數名稱，例如 ANTHROPIC_API_KEY
+func ProviderKeyEnv(provider string) string {
+	return providerEnvPrefix(provider) + "_API_KEY"
+}
+
+// providerEnvPrefix 返回提供者環境變數的前綴，例如 openai-chat 為 OPENAI_CHAT
+func providerEnvPrefix(provider string) string {
+	return strings.ToUpper(strings.ReplaceAll(provider, "-", "_"))
 }
 
 // IsProduction 檢查是否為生產環境
@@ -53,6 +307,18 @@ func getEnvOrDefault(key, defaultValue string) string {
 	return value
 }
 
+// splitList 將以逗號分隔的字串拆分為去除空白的列表
+func splitList(value string) []string {
+	items := []string{}
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 // GetPort 返回服務器端口
 func GetPort() string {
 	port := os.Getenv("PORT")
@@ -92,6 +358,11 @@ func GetMaxContentLength() int {
 	return 100000 // 約 100KB
 }
 
+// GetMaxHTMLSize 返回原始 HTML 的大小限制
+func GetMaxHTMLSize() int {
+	return 1024 * 1024 * 2 // 2MB
+}
+
 // GetMaxImageSize 返回最大圖像大小限制
 func GetMaxImageSize() int {
 	return 1024 * 1024 * 5 // 5MB
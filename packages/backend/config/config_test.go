@@ -0,0 +1,96 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseModelRef(t *testing.T) {
+	t.Setenv(EnvAzureOpenAIDeployment, "")
+
+	tests := []struct {
+		value string
+		want  ModelRef
+	}{
+		{"openai:gpt-4o", ModelRef{Provider: "openai", Model: "gpt-4o"}},
+		{" Anthropic : claude-3-opus ", ModelRef{Provider: "anthropic", Model: "claude-3-opus"}},
+		// 模型名稱可以包含冒號，例如 Ollama 的標籤
+		{"ollama:llama3:8b", ModelRef{Provider: "ollama", Model: "llama3:8b"}},
+		{"gemini", ModelRef{Provider: "gemini", Model: "gemini-2.0-flash"}},
+		{"anthropic:", ModelRef{Provider: "anthropic", Model: "claude-3-5-sonnet-latest"}},
+		{"unknown", ModelRef{Provider: "unknown", Model: "gpt-4o-mini"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			if got := ParseModelRef(tt.value); got != tt.want {
+				t.Errorf("ParseModelRef(%q) = %+v, want %+v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseModelRefAzureDeployment(t *testing.T) {
+	t.Setenv(EnvAzureOpenAIDeployment, "my-deployment")
+
+	if got := ParseModelRef("azure"); got.Model != "my-deployment" {
+		t.Errorf("ParseModelRef(azure).Model = %q, want my-deployment", got.Model)
+	}
+	if got := ParseModelRef("azure:other"); got.Model != "other" {
+		t.Errorf("ParseModelRef(azure:other).Model = %q, want other", got.Model)
+	}
+}
+
+func TestParseModelRefs(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []ModelRef
+	}{
+		{"", []ModelRef{}},
+		{" , ", []ModelRef{}},
+		{
+			"openai:gpt-4o, anthropic:claude-3-opus,",
+			[]ModelRef{{Provider: "openai", Model: "gpt-4o"}, {Provider: "anthropic", Model: "claude-3-opus"}},
+		},
+	}
+
+	for _, tt := range tests {
+		if got := ParseModelRefs(tt.value); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseModelRefs(%q) = %+v, want %+v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestParseAllowedModels(t *testing.T) {
+	got := parseAllowedModels("gpt-4o, anthropic:claude-3-opus", "openai")
+	want := []ModelRef{{Provider: "openai", Model: "gpt-4o"}, {Provider: "anthropic", Model: "claude-3-opus"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseAllowedModels = %+v, want %+v", got, want)
+	}
+}
+
+func TestOriginAllowed(t *testing.T) {
+	tests := []struct {
+		name    string
+		origins []string
+		origin  string
+		want    bool
+	}{
+		{"chrome extension", nil, "chrome-extension://abcdef", true},
+		{"firefox extension", nil, "moz-extension://1234", true},
+		{"arbitrary site", nil, "https://evil.example", false},
+		{"listed site", []string{"https://app.example.com/"}, "https://app.example.com", true},
+		{"listed site is case insensitive", []string{"https://App.Example.com"}, "https://app.example.com", true},
+		{"unlisted site", []string{"https://app.example.com"}, "https://other.example.com", false},
+		{"wildcard", []string{"*"}, "https://any.example", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Config{WSOrigins: tt.origins}
+			if got := c.OriginAllowed(tt.origin); got != tt.want {
+				t.Errorf("OriginAllowed(%q) = %v, want %v", tt.origin, got, tt.want)
+			}
+		})
+	}
+}
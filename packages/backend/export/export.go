@@ -0,0 +1,221 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"strings"
+	"time"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+
+	"github.com/rocker15962/llm-web-assistant/packages/backend/models"
+)
+
+// 匯出格式
+const (
+	FormatMarkdown = "md"
+	FormatJSON     = "json"
+	FormatHTML     = "html"
+)
+
+// formats 各格式的 Content-Type 和副檔名
+var formats = map[string]struct {
+	contentType string
+	extension   string
+}{
+	FormatMarkdown: {"text/markdown; charset=utf-8", "md"},
+	FormatJSON:     {"application/json; charset=utf-8", "json"},
+	FormatHTML:     {"text/html; charset=utf-8", "html"},
+}
+
+// NewDocument 以頁面資訊和問答輪次建立匯出文件
+func NewDocument(title, url string, turns []models.ConversationTurn) models.ExportDocument {
+	doc := models.ExportDocument{
+		Title:      title,
+		URL:        url,
+		ExportedAt: time.Now(),
+		Turns:      []models.ExportTurn{},
+	}
+
+	for _, turn := range turns {
+		doc.Turns = append(doc.Turns, models.ExportTurn{
+			Question:  turn.Question,
+			Answer:    turn.Answer,
+			Usage:     turn.Usage,
+			Provider:  turn.Provider,
+			Model:     turn.Model,
+			CreatedAt: turn.CreatedAt,
+		})
+		doc.Usage.PromptTokens += turn.Usage.PromptTokens
+		doc.Usage.CompletionTokens += turn.Usage.CompletionTokens
+		doc.Usage.TotalTokens += turn.Usage.TotalTokens
+	}
+
+	return doc
+}
+
+// Render 以指定格式輸出文件，返回內容、Content-Type 和建議的檔名
+func Render(doc models.ExportDocument, format string) ([]byte, string, string, error) {
+	info, ok := formats[format]
+	if !ok {
+		return nil, "", "", fmt.Errorf("不支援的匯出格式: %s", format)
+	}
+
+	var (
+		data []byte
+		err  error
+	)
+	switch format {
+	case FormatMarkdown:
+		data = []byte(renderMarkdown(doc))
+	case FormatJSON:
+		data, err = json.MarshalIndent(doc, "", "  ")
+	case FormatHTML:
+		data, err = renderHTML(doc)
+	}
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	filename := fmt.Sprintf("conversation-%s.%s", doc.ExportedAt.Format("20060102-150405"), info.extension)
+	return data, info.contentType, filename, nil
+}
+
+// renderMarkdown 輸出 Markdown 文件，回答本身已是 Markdown，原樣保留
+func renderMarkdown(doc models.ExportDocument) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# %s\n\n", documentTitle(doc))
+	if doc.URL != "" {
+		fmt.Fprintf(&b, "網址：<%s>\n\n", doc.URL)
+	}
+	fmt.Fprintf(&b, "匯出時間：%s\n\n", doc.ExportedAt.Format("2006-01-02 15:04:05"))
+
+	for i, turn := range doc.Turns {
+		b.WriteString("---\n\n")
+		fmt.Fprintf(&b, "## 問題 %d\n\n", i+1)
+		fmt.Fprintf(&b, "%s\n\n", quote(turn.Question))
+		fmt.Fprintf(&b, "### 回答\n\n%s\n\n", strings.TrimSpace(turn.Answer))
+		fmt.Fprintf(&b, "*%s*\n\n", turnMeta(turn))
+	}
+
+	b.WriteString("---\n\n")
+	fmt.Fprintf(&b, "總 token 使用量：%d（提示詞 %d，回答 %d）\n",
+		doc.Usage.TotalTokens, doc.Usage.PromptTokens, doc.Usage.CompletionTokens)
+
+	return b.String()
+}
+
+// quote 將文字轉為 Markdown 引用區塊
+func quote(text string) string {
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	for i, line := range lines {
+		lines[i] = "> " + line
+	}
+	return strings.Join(lines, "\n")
+}
+
+// turnMeta 返回一輪問答的模型與 token 使用量說明
+func turnMeta(turn models.ExportTurn) string {
+	meta := fmt.Sprintf("token 使用量：%d（提示詞 %d，回答 %d）",
+		turn.Usage.TotalTokens, turn.Usage.PromptTokens, turn.Usage.CompletionTokens)
+	if turn.Model != "" {
+		meta = fmt.Sprintf("模型：%s · %s", modelLabel(turn), meta)
+	}
+	return meta
+}
+
+// modelLabel 返回 provider/model 格式的模型名稱
+func modelLabel(turn models.ExportTurn) string {
+	if turn.Provider == "" {
+		return turn.Model
+	}
+	return turn.Provider + "/" + turn.Model
+}
+
+// documentTitle 返回文件標題，頁面沒有標題時使用默認值
+func documentTitle(doc models.ExportDocument) string {
+	if strings.TrimSpace(doc.Title) == "" {
+		return "網頁助手對話"
+	}
+	return doc.Title
+}
+
+// markdown 將回答的 Markdown 轉為 HTML，原始 HTML 會被忽略以避免注入
+var markdown = goldmark.New(goldmark.WithExtensions(extension.GFM))
+
+// htmlTurn 是 HTML 模板使用的一輪問答
+type htmlTurn struct {
+	Question string
+	Answer   template.HTML
+	Meta     string
+}
+
+// htmlTemplate 自帶樣式的單一 HTML 文件，方便直接分享或貼到 wiki
+var htmlTemplate = template.Must(template.New("export").Funcs(template.FuncMap{
+	"inc": func(i int) int { return i + 1 },
+}).Parse(`<!DOCTYPE html>
+<html lang="zh-Hant">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", "Noto Sans TC", sans-serif; max-width: 860px; margin: 2em auto; padding: 0 1em; line-height: 1.6; color: #222; }
+header { border-bottom: 1px solid #ddd; margin-bottom: 1.5em; }
+.turn { border-bottom: 1px solid #eee; padding-bottom: 1em; margin-bottom: 1.5em; }
+.question { background: #f4f6f8; border-left: 4px solid #4a90d9; padding: 0.5em 1em; white-space: pre-wrap; }
+.meta, .muted { color: #777; font-size: 0.9em; }
+pre { background: #f6f8fa; padding: 0.8em; overflow-x: auto; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ddd; padding: 0.3em 0.6em; }
+</style>
+</head>
+<body>
+<header>
+<h1>{{.Title}}</h1>
+{{if .URL}}<p>網址：<a href="{{.URL}}">{{.URL}}</a></p>{{end}}
+<p class="muted">匯出時間：{{.ExportedAt}}</p>
+</header>
+{{range $i, $turn := .Turns}}<section class="turn">
+<h2>問題 {{inc $i}}</h2>
+<div class="question">{{$turn.Question}}</div>
+<h3>回答</h3>
+<div class="answer">{{$turn.Answer}}</div>
+<p class="meta">{{$turn.Meta}}</p>
+</section>
+{{end}}<footer class="muted">總 token 使用量：{{.Usage.TotalTokens}}（提示詞 {{.Usage.PromptTokens}}，回答 {{.Usage.CompletionTokens}}）</footer>
+</body>
+</html>
+`))
+
+// renderHTML 輸出 HTML 文件
+func renderHTML(doc models.ExportDocument) ([]byte, error) {
+	turns := []htmlTurn{}
+	for _, turn := range doc.Turns {
+		var answer bytes.Buffer
+		if err := markdown.Convert([]byte(turn.Answer), &answer); err != nil {
+			return nil, err
+		}
+		turns = append(turns, htmlTurn{
+			Question: strings.TrimSpace(turn.Question),
+			Answer:   template.HTML(answer.String()),
+			Meta:     turnMeta(turn),
+		})
+	}
+
+	var out bytes.Buffer
+	err := htmlTemplate.Execute(&out, map[string]interface{}{
+		"Title":      documentTitle(doc),
+		"URL":        doc.URL,
+		"ExportedAt": doc.ExportedAt.Format("2006-01-02 15:04:05"),
+		"Turns":      turns,
+		"Usage":      doc.Usage,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
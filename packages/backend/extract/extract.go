@@ -0,0 +1,280 @@
+package extract
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Result 是從 HTML 提取的頁面內容
+type Result struct {
+	Title    string
+	Markdown string
+}
+
+// removedTags 一律移除的元素，通常不包含正文
+// 只移除表單控制項而不移除 form 本身，ASP.NET WebForms 等頁面會以 form 包住整個 body
+var removedTags = map[atom.Atom]bool{
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Noscript: true,
+	atom.Template: true,
+	atom.Svg:      true,
+	atom.Canvas:   true,
+	atom.Iframe:   true,
+	atom.Object:   true,
+	atom.Embed:    true,
+	atom.Button:   true,
+	atom.Input:    true,
+	atom.Select:   true,
+	atom.Textarea: true,
+	atom.Nav:      true,
+	atom.Header:   true,
+	atom.Footer:   true,
+	atom.Aside:    true,
+	atom.Dialog:   true,
+}
+
+// boilerplateRoles 表示導覽、頁首頁尾等非正文區塊的 ARIA role
+var boilerplateRoles = map[string]bool{
+	"navigation":    true,
+	"banner":        true,
+	"contentinfo":   true,
+	"complementary": true,
+	"dialog":        true,
+	"alertdialog":   true,
+	"search":        true,
+	"menu":          true,
+	"menubar":       true,
+}
+
+// boilerplatePattern 匹配常見非正文區塊的 class 或 id
+var boilerplatePattern = regexp.MustCompile(`(?i)(^|[-_ ])(nav|navbar|menu|breadcrumbs?|footer|header|masthead|sidebar|cookies?|consent|gdpr|banner|ads?|advert\w*|sponsor\w*|promo\w*|share|sharing|social|comments?|related|recommend\w*|subscribe|newsletter|popup|modal|overlay|skip-link|toolbar|pagination)($|[-_ ])`)
+
+// contentPattern 匹配正文容器的 class 或 id，避免被誤判為非正文
+var contentPattern = regexp.MustCompile(`(?i)(^|[-_ ])(article|content|main|post|entry|story|body|text)($|[-_ ])`)
+
+// Extract 解析 HTML，移除導覽、橫幅、頁尾等非正文內容，並將主要內容轉為精簡的 Markdown
+func Extract(source string) (Result, error) {
+	doc, err := html.Parse(strings.NewReader(source))
+	if err != nil {
+		return Result{}, fmt.Errorf("解析 HTML 失敗: %w", err)
+	}
+
+	title := strings.TrimSpace(textContent(findFirst(doc, atom.Title)))
+
+	body := findFirst(doc, atom.Body)
+	if body == nil {
+		body = doc
+	}
+	removeBoilerplate(body, false)
+
+	root := mainContent(body)
+	markdown := renderMarkdown(root)
+	if markdown == "" && root != body {
+		// 選取的區塊沒有可用內容時改用整個 body
+		markdown = renderMarkdown(body)
+	}
+
+	return Result{Title: title, Markdown: markdown}, nil
+}
+
+// removeBoilerplate 移除不屬於正文的元素
+// inArticle 表示位於 main 或 article 內，其中的 header、footer 通常是文章標題與署名，予以保留
+func removeBoilerplate(n *html.Node, inArticle bool) {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		if c.Type == html.CommentNode || (c.Type == html.ElementNode && isBoilerplate(c, inArticle)) {
+			n.RemoveChild(c)
+		} else {
+			removeBoilerplate(c, inArticle || c.DataAtom == atom.Main || c.DataAtom == atom.Article)
+		}
+		c = next
+	}
+}
+
+// isBoilerplate 判斷元素是否為非正文內容
+func isBoilerplate(n *html.Node, inArticle bool) bool {
+	if inArticle && (n.DataAtom == atom.Header || n.DataAtom == atom.Footer) {
+		return false
+	}
+	if removedTags[n.DataAtom] {
+		return true
+	}
+	if boilerplateRoles[strings.ToLower(attr(n, "role"))] {
+		return true
+	}
+	if hasAttr(n, "hidden") || attr(n, "aria-hidden") == "true" {
+		return true
+	}
+	style := strings.ReplaceAll(strings.ToLower(attr(n, "style")), " ", "")
+	if strings.Contains(style, "display:none") || strings.Contains(style, "visibility:hidden") {
+		return true
+	}
+
+	// main、article 等語義元素總是保留
+	if n.DataAtom == atom.Main || n.DataAtom == atom.Article || n.DataAtom == atom.Body {
+		return false
+	}
+	names := attr(n, "class") + " " + attr(n, "id")
+	return boilerplatePattern.MatchString(names) && !contentPattern.MatchString(names)
+}
+
+// mainContent 選取頁面的主要內容區塊
+// 優先使用 main 或 role="main"，其次是文字最多的 article，最後以段落文字量評分選出容器
+func mainContent(body *html.Node) *html.Node {
+	if main := findFirstMatch(body, func(n *html.Node) bool {
+		return n.DataAtom == atom.Main || attr(n, "role") == "main"
+	}); main != nil && textLength(main) > 0 {
+		return main
+	}
+
+	var best *html.Node
+	bestLen := 0
+	walk(body, func(n *html.Node) {
+		if n.DataAtom == atom.Article {
+			if length := textLength(n); length > bestLen {
+				best, bestLen = n, length
+			}
+		}
+	})
+	if best != nil {
+		return best
+	}
+
+	return scoreCandidates(body)
+}
+
+// scoreCandidates 以 readability 的方式評分：每個段落的文字量計入父元素，
+// 一半計入祖父元素，再依連結文字比例扣分，返回分數最高的容器
+func scoreCandidates(body *html.Node) *html.Node {
+	scores := map[*html.Node]float64{}
+	walk(body, func(n *html.Node) {
+		switch n.DataAtom {
+		case atom.P, atom.Pre, atom.Td, atom.Blockquote, atom.Li:
+		default:
+			return
+		}
+		length := len([]rune(strings.TrimSpace(textContent(n))))
+		if length < 25 {
+			return
+		}
+		// 基本分加上每 100 字 1 分，最多 3 分
+		score := 1 + float64(length)/100
+		if score > 4 {
+			score = 4
+		}
+		if parent := n.Parent; parent != nil {
+			scores[parent] += score
+			if grandparent := parent.Parent; grandparent != nil {
+				scores[grandparent] += score / 2
+			}
+		}
+	})
+
+	var best *html.Node
+	bestScore := 0.0
+	for n, score := range scores {
+		score *= 1 - linkDensity(n)
+		if score > bestScore {
+			best, bestScore = n, score
+		}
+	}
+	if best == nil {
+		return body
+	}
+	return best
+}
+
+// linkDensity 返回元素中連結文字所佔的比例
+func linkDensity(n *html.Node) float64 {
+	total := textLength(n)
+	if total == 0 {
+		return 0
+	}
+	links := 0
+	walk(n, func(c *html.Node) {
+		if c.DataAtom == atom.A {
+			links += textLength(c)
+		}
+	})
+	return float64(links) / float64(total)
+}
+
+// walk 以深度優先順序訪問所有元素節點
+func walk(n *html.Node, visit func(*html.Node)) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode {
+			visit(c)
+			walk(c, visit)
+		}
+	}
+}
+
+// findFirst 返回第一個指定標籤的元素
+func findFirst(n *html.Node, tag atom.Atom) *html.Node {
+	return findFirstMatch(n, func(c *html.Node) bool { return c.DataAtom == tag })
+}
+
+// findFirstMatch 返回第一個符合條件的元素
+func findFirstMatch(n *html.Node, match func(*html.Node) bool) *html.Node {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode {
+			continue
+		}
+		if match(c) {
+			return c
+		}
+		if found := findFirstMatch(c, match); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// textContent 返回元素內所有文字
+func textContent(n *html.Node) string {
+	if n == nil {
+		return ""
+	}
+	var b strings.Builder
+	var collect func(*html.Node)
+	collect = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			collect(c)
+		}
+	}
+	collect(n)
+	return b.String()
+}
+
+// textLength 返回元素內去除空白後的字元數
+func textLength(n *html.Node) int {
+	return len([]rune(strings.Join(strings.Fields(textContent(n)), "")))
+}
+
+// attr 返回元素的屬性值
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// hasAttr 判斷元素是否有指定屬性
+func hasAttr(n *html.Node, key string) bool {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return true
+		}
+	}
+	return false
+}
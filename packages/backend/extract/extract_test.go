@@ -0,0 +1,92 @@
+package extract
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExtract(t *testing.T) {
+	article := "<p>這是一篇關於量子電腦的文章，說明量子位元如何同時處於多種狀態並進行運算。</p>"
+
+	tests := []struct {
+		name      string
+		source    string
+		wantTitle string
+		contains  []string
+		excludes  []string
+	}{
+		{
+			name:      "title and main content",
+			source:    `<html><head><title> 量子電腦 </title></head><body><main><h1>簡介</h1>` + article + `</main></body></html>`,
+			wantTitle: "量子電腦",
+			contains:  []string{"# 簡介", "量子位元"},
+		},
+		{
+			name: "boilerplate removed",
+			source: `<body><nav>首頁 關於我們</nav><header>網站橫幅</header>
+				<div class="cookie-banner">本站使用 cookies</div>
+				<article>` + article + `</article>
+				<aside>相關文章</aside><footer>版權所有</footer>
+				<script>var tracking = 1;</script></body>`,
+			contains: []string{"量子位元"},
+			excludes: []string{"首頁", "網站橫幅", "cookies", "相關文章", "版權所有", "tracking"},
+		},
+		{
+			name:     "hidden elements removed",
+			source:   `<body><main>` + article + `<div hidden>隱藏一</div><div style="display: none">隱藏二</div><p aria-hidden="true">隱藏三</p></main></body>`,
+			contains: []string{"量子位元"},
+			excludes: []string{"隱藏一", "隱藏二", "隱藏三"},
+		},
+		{
+			name:     "article header and footer kept",
+			source:   `<body><article><header><h1>文章標題</h1></header>` + article + `<footer>作者：王小明</footer></article></body>`,
+			contains: []string{"# 文章標題", "作者：王小明"},
+		},
+		{
+			// ASP.NET WebForms 以 form 包住整個頁面，只移除表單控制項
+			name: "form wrapper kept",
+			source: `<body><form id="form1" method="post"><div class="content">` + article + `
+				<input type="text" value="搜尋"><button>送出</button></div></form></body>`,
+			contains: []string{"量子位元"},
+			excludes: []string{"送出"},
+		},
+		{
+			name: "readability scoring picks the densest container",
+			source: `<body><div id="links"><p><a href="/a">連結一連結一連結一連結一連結一連結一連結一</a></p></div>
+				<div id="story">` + article + article + `</div></body>`,
+			contains: []string{"量子位元"},
+			excludes: []string{"連結一"},
+		},
+		{
+			name:     "lists and tables",
+			source:   `<body><main><ul><li>第一項</li><li>第二項</li></ul><table><tr><th>名稱</th><th>數量</th></tr><tr><td>蘋果</td><td>3</td></tr></table></main></body>`,
+			contains: []string{"- 第一項", "- 第二項", "| 名稱 | 數量 |", "| 蘋果 | 3 |"},
+		},
+		{
+			name:   "empty document",
+			source: ``,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Extract(tt.source)
+			if err != nil {
+				t.Fatalf("Extract: %v", err)
+			}
+			if got.Title != tt.wantTitle {
+				t.Errorf("Title = %q, want %q", got.Title, tt.wantTitle)
+			}
+			for _, want := range tt.contains {
+				if !strings.Contains(got.Markdown, want) {
+					t.Errorf("Markdown lacks %q:\n%s", want, got.Markdown)
+				}
+			}
+			for _, unwanted := range tt.excludes {
+				if strings.Contains(got.Markdown, unwanted) {
+					t.Errorf("Markdown contains %q:\n%s", unwanted, got.Markdown)
+				}
+			}
+		})
+	}
+}
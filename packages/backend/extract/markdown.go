@@ -0,0 +1,281 @@
+package extract
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// headingLevels 標題元素對應的 Markdown 層級
+var headingLevels = map[atom.Atom]int{
+	atom.H1: 1, atom.H2: 2, atom.H3: 3, atom.H4: 4, atom.H5: 5, atom.H6: 6,
+}
+
+// blockTags 視為區塊的容器元素，其內容前後換行
+var blockTags = map[atom.Atom]bool{
+	atom.Div: true, atom.Section: true, atom.Article: true, atom.Main: true,
+	atom.Header: true, atom.Footer: true, atom.Figure: true, atom.Figcaption: true,
+	atom.Dl: true, atom.Dt: true, atom.Dd: true, atom.Details: true, atom.Summary: true,
+	atom.Address: true, atom.Body: true, atom.Center: true,
+}
+
+var (
+	spacePattern     = regexp.MustCompile(`[ \t\r\n\f]+`)
+	blankLinePattern = regexp.MustCompile(`\n{3,}`)
+)
+
+// renderMarkdown 將元素轉為 Markdown
+func renderMarkdown(root *html.Node) string {
+	var b strings.Builder
+	renderBlocks(&b, root)
+
+	lines := strings.Split(b.String(), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " ")
+	}
+	text := blankLinePattern.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")
+	return strings.TrimSpace(text)
+}
+
+// renderBlocks 輸出元素的子節點，區塊元素之間以空行分隔
+func renderBlocks(b *strings.Builder, n *html.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.TextNode {
+			b.WriteString(collapse(c.Data))
+			continue
+		}
+		if c.Type != html.ElementNode {
+			continue
+		}
+
+		if level, ok := headingLevels[c.DataAtom]; ok {
+			if text := strings.TrimSpace(renderInline(c)); text != "" {
+				b.WriteString("\n\n" + strings.Repeat("#", level) + " " + text + "\n\n")
+			}
+			continue
+		}
+
+		switch {
+		case c.DataAtom == atom.P:
+			b.WriteString("\n\n" + strings.TrimSpace(renderInline(c)) + "\n\n")
+		case c.DataAtom == atom.Pre:
+			b.WriteString("\n\n" + renderCodeBlock(c) + "\n\n")
+		case c.DataAtom == atom.Ul || c.DataAtom == atom.Ol:
+			b.WriteString("\n\n" + renderList(c, 0) + "\n\n")
+		case c.DataAtom == atom.Table:
+			b.WriteString("\n\n" + renderTable(c) + "\n\n")
+		case c.DataAtom == atom.Blockquote:
+			b.WriteString("\n\n" + quoteLines(renderMarkdown(c)) + "\n\n")
+		case c.DataAtom == atom.Hr:
+			b.WriteString("\n\n---\n\n")
+		case c.DataAtom == atom.Br:
+			b.WriteString("\n")
+		case c.DataAtom == atom.Img:
+			// 圖片不放入提示詞
+		case blockTags[c.DataAtom]:
+			b.WriteString("\n\n")
+			renderBlocks(b, c)
+			b.WriteString("\n\n")
+		default:
+			// 行內元素，或未知元素中可能包含區塊
+			if containsBlock(c) {
+				renderBlocks(b, c)
+			} else {
+				b.WriteString(renderInline(c))
+			}
+		}
+	}
+}
+
+// renderInline 輸出行內內容
+func renderInline(n *html.Node) string {
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		switch c.Type {
+		case html.TextNode:
+			b.WriteString(collapse(c.Data))
+			continue
+		case html.ElementNode:
+		default:
+			continue
+		}
+
+		switch c.DataAtom {
+		case atom.Strong, atom.B:
+			b.WriteString(wrap(renderInline(c), "**"))
+		case atom.Em, atom.I:
+			b.WriteString(wrap(renderInline(c), "*"))
+		case atom.Code, atom.Kbd, atom.Samp:
+			b.WriteString(wrap(collapse(textContent(c)), "`"))
+		case atom.Br:
+			b.WriteString("\n")
+		case atom.Img:
+			// 圖片不放入提示詞
+		case atom.Ul, atom.Ol:
+			b.WriteString("\n" + renderList(c, 0) + "\n")
+		default:
+			// 連結只保留文字，讓內容更精簡
+			b.WriteString(renderInline(c))
+		}
+	}
+	return b.String()
+}
+
+// renderCodeBlock 輸出保留原始縮排的程式碼區塊
+func renderCodeBlock(pre *html.Node) string {
+	lang := codeLanguage(pre)
+	if code := findFirst(pre, atom.Code); code != nil && lang == "" {
+		lang = codeLanguage(code)
+	}
+	code := strings.Trim(textContent(pre), "\n")
+	return "```" + lang + "\n" + code + "\n```"
+}
+
+// codeLanguage 從 class="language-xxx" 取得程式語言
+func codeLanguage(n *html.Node) string {
+	for _, class := range strings.Fields(attr(n, "class")) {
+		for _, prefix := range []string{"language-", "lang-"} {
+			if strings.HasPrefix(class, prefix) {
+				return strings.TrimPrefix(class, prefix)
+			}
+		}
+	}
+	return ""
+}
+
+// renderList 輸出列表，巢狀列表以縮排表示
+func renderList(list *html.Node, depth int) string {
+	var lines []string
+	indent := strings.Repeat("  ", depth)
+	index := 1
+	if start, err := strconv.Atoi(attr(list, "start")); err == nil {
+		index = start
+	}
+
+	for li := list.FirstChild; li != nil; li = li.NextSibling {
+		if li.Type != html.ElementNode || li.DataAtom != atom.Li {
+			continue
+		}
+
+		marker := "- "
+		if list.DataAtom == atom.Ol {
+			marker = strconv.Itoa(index) + ". "
+			index++
+		}
+
+		var text strings.Builder
+		var nested []string
+		for c := li.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.ElementNode && (c.DataAtom == atom.Ul || c.DataAtom == atom.Ol) {
+				nested = append(nested, renderList(c, depth+1))
+				continue
+			}
+			if c.Type == html.TextNode {
+				text.WriteString(collapse(c.Data))
+			} else if c.Type == html.ElementNode {
+				text.WriteString(" " + renderInline(c) + " ")
+			}
+		}
+
+		item := collapse(text.String())
+		if item != "" || len(nested) > 0 {
+			lines = append(lines, indent+marker+strings.TrimSpace(item))
+		}
+		lines = append(lines, nested...)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// renderTable 輸出 GFM 表格，第一列作為表頭；只有一欄的排版表格按區塊輸出
+func renderTable(table *html.Node) string {
+	var rows [][]string
+	var collectRows func(*html.Node)
+	collectRows = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode {
+				continue
+			}
+			switch c.DataAtom {
+			case atom.Thead, atom.Tbody, atom.Tfoot:
+				collectRows(c)
+			case atom.Tr:
+				var cells []string
+				for cell := c.FirstChild; cell != nil; cell = cell.NextSibling {
+					if cell.Type == html.ElementNode && (cell.DataAtom == atom.Td || cell.DataAtom == atom.Th) {
+						text := collapse(strings.ReplaceAll(renderInline(cell), "\n", " "))
+						cells = append(cells, strings.ReplaceAll(strings.TrimSpace(text), "|", `\|`))
+					}
+				}
+				if len(cells) > 0 {
+					rows = append(rows, cells)
+				}
+			}
+		}
+	}
+	collectRows(table)
+
+	columns := 0
+	for _, row := range rows {
+		if len(row) > columns {
+			columns = len(row)
+		}
+	}
+	if len(rows) == 0 {
+		return ""
+	}
+	if columns < 2 {
+		var b strings.Builder
+		renderBlocks(&b, table)
+		return b.String()
+	}
+
+	var lines []string
+	for i, row := range rows {
+		for len(row) < columns {
+			row = append(row, "")
+		}
+		lines = append(lines, "| "+strings.Join(row, " | ")+" |")
+		if i == 0 {
+			lines = append(lines, "|"+strings.Repeat(" --- |", columns))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// containsBlock 判斷元素內是否包含區塊元素
+func containsBlock(n *html.Node) bool {
+	return findFirstMatch(n, func(c *html.Node) bool {
+		_, heading := headingLevels[c.DataAtom]
+		switch c.DataAtom {
+		case atom.P, atom.Pre, atom.Ul, atom.Ol, atom.Table, atom.Blockquote, atom.Hr:
+			return true
+		}
+		return heading || blockTags[c.DataAtom]
+	}) != nil
+}
+
+// quoteLines 為每一行加上引用標記
+func quoteLines(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight("> "+line, " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// wrap 以標記包住文字，保留兩側空白在標記之外
+func wrap(text, marker string) string {
+	trimmed := strings.TrimSpace(text)
+	if trimmed == "" {
+		return text
+	}
+	return strings.Replace(text, trimmed, marker+trimmed+marker, 1)
+}
+
+// collapse 將連續空白合併為單一空格
+func collapse(text string) string {
+	return spacePattern.ReplaceAllString(text, " ")
+}
@@ -0,0 +1,185 @@
+package extract
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/ledongthuc/pdf"
+)
+
+const (
+	// maxPDFPages 最多提取的頁數，避免惡意檔案耗盡資源
+	maxPDFPages = 2000
+	// pdfSpaceThreshold TJ 陣列中超過此位移（千分之一字寬）時視為單字間的空白
+	pdfSpaceThreshold = 200
+)
+
+// PDFDocument 是從 PDF 提取的文字
+type PDFDocument struct {
+	Title string
+	Pages []string // 每頁的文字，索引 0 為第 1 頁；沒有文字的頁面為空字串
+}
+
+// PDF 以純 Go 解析 PDF，逐頁提取文字
+// 掃描檔等沒有文字層的頁面會是空字串，不會返回錯誤
+func PDF(data []byte) (doc PDFDocument, err error) {
+	// PDF 解析庫遇到損壞的檔案時會 panic
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("解析 PDF 失敗: %v", r)
+		}
+	}()
+
+	reader, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return doc, fmt.Errorf("解析 PDF 失敗: %w", err)
+	}
+
+	doc.Title = strings.TrimSpace(reader.Trailer().Key("Info").Key("Title").Text())
+
+	pages := reader.NumPage()
+	if pages > maxPDFPages {
+		return doc, fmt.Errorf("PDF 頁數過多: %d，上限為 %d", pages, maxPDFPages)
+	}
+	for i := 1; i <= pages; i++ {
+		doc.Pages = append(doc.Pages, pdfPageText(reader.Page(i)))
+	}
+	return doc, nil
+}
+
+// pdfPageText 解析頁面的內容串流，依文字定位運算子還原換行和單字間的空白
+// 單頁解析失敗時返回空字串，不影響其他頁面
+func pdfPageText(page pdf.Page) (text string) {
+	defer func() {
+		if r := recover(); r != nil {
+			text = ""
+		}
+	}()
+	if page.V.IsNull() {
+		return ""
+	}
+
+	encodings := map[string]pdf.TextEncoding{}
+	for _, name := range page.Fonts() {
+		encodings[name] = page.Font(name).Encoder()
+	}
+
+	var b strings.Builder
+	var enc pdf.TextEncoding = rawEncoding{}
+	// 記錄文字位置和上次定位後的字數，以平均字寬 0.6 em 估計同一行的兩段文字之間是否有空白
+	lastX, lastY, hasPosition := 0.0, 0.0, false
+	fontSize, scale, written := 0.0, 1.0, 0
+	write := func(s string) {
+		text := enc.Decode(s)
+		written += len([]rune(text))
+		b.WriteString(text)
+	}
+	separate := func(sep string) {
+		current := b.String()
+		if current != "" && !strings.HasSuffix(current, "\n") && !strings.HasSuffix(current, sep) {
+			b.WriteString(sep)
+		}
+	}
+
+	interpret := func(stream pdf.Value) {
+		pdf.Interpret(stream, func(stk *pdf.Stack, op string) {
+			args := make([]pdf.Value, stk.Len())
+			for i := len(args) - 1; i >= 0; i-- {
+				args[i] = stk.Pop()
+			}
+
+			switch op {
+			case "Tf": // 設定字型
+				if len(args) == 2 {
+					fontSize = args[1].Float64()
+					if e, ok := encodings[args[0].Name()]; ok {
+						enc = e
+					} else {
+						enc = rawEncoding{}
+					}
+				}
+			case "Td", "TD": // 移動文字位置，垂直移動表示換行，水平移動超過估計字寬表示空白
+				if len(args) == 2 {
+					switch {
+					case args[1].Float64() != 0:
+						separate("\n")
+					case args[0].Float64()-float64(written)*fontSize*0.6 > fontSize*0.25:
+						separate(" ")
+					}
+					written = 0
+				}
+			case "Tm": // 設定文字矩陣，y 座標改變表示換行
+				if len(args) == 6 {
+					x, y := args[4].Float64(), args[5].Float64()
+					em := fontSize * math.Abs(args[0].Float64())
+					if em == 0 {
+						em = fontSize * scale
+					}
+					switch {
+					case hasPosition && y != lastY:
+						separate("\n")
+					case hasPosition && x-(lastX+float64(written)*em*0.6) > em*0.25:
+						separate(" ")
+					}
+					lastX, lastY, hasPosition = x, y, true
+					scale, written = math.Abs(args[0].Float64()), 0
+				}
+			case "T*":
+				separate("\n")
+			case "'", "\"": // 換行後顯示文字
+				separate("\n")
+				if len(args) > 0 {
+					write(args[len(args)-1].RawString())
+				}
+			case "Tj":
+				if len(args) == 1 {
+					write(args[0].RawString())
+				}
+			case "TJ": // 文字陣列，數字為字距調整，較大的負位移通常是空白
+				if len(args) == 1 {
+					for i := 0; i < args[0].Len(); i++ {
+						item := args[0].Index(i)
+						if item.Kind() == pdf.String {
+							write(item.RawString())
+						} else if -item.Float64() > pdfSpaceThreshold {
+							separate(" ")
+						}
+					}
+				}
+			}
+		})
+	}
+
+	contents := page.V.Key("Contents")
+	if contents.Kind() == pdf.Array {
+		for i := 0; i < contents.Len(); i++ {
+			interpret(contents.Index(i))
+			separate("\n")
+		}
+	} else {
+		interpret(contents)
+	}
+
+	return normalizePDFText(b.String())
+}
+
+// normalizePDFText 合併每行內的連續空白並移除空行
+func normalizePDFText(text string) string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// rawEncoding 在找不到字型編碼時直接使用原始位元組
+type rawEncoding struct{}
+
+// Decode 返回原始文字
+func (rawEncoding) Decode(raw string) string {
+	return raw
+}
@@ -0,0 +1,90 @@
+package extract
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// buildPDF 構建最小的 PDF，每個內容串流為一頁，使用 WinAnsi 編碼的 Helvetica 字型
+func buildPDF(title string, contents ...string) []byte {
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"", // 頁面樹，頁面物件建立後填入
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+		fmt.Sprintf("<< /Title (%s) >>", title),
+	}
+
+	var kids []string
+	for _, content := range contents {
+		page := len(objects) + 1
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>", page+1),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content)+1, content),
+		)
+		kids = append(kids, fmt.Sprintf("%d 0 R", page))
+	}
+	objects[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(kids))
+
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R /Info 4 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return b.Bytes()
+}
+
+func TestPDF(t *testing.T) {
+	data := buildPDF("Quantum Notes",
+		"BT /F1 12 Tf 72 720 Td (First line) Tj 0 -14 Td (Second line) Tj ET",
+		"BT /F1 12 Tf 72 720 Td [(Quantum) -300 (bits) 20 (!)] TJ T* (Next) Tj ET",
+		"",
+	)
+
+	doc, err := PDF(data)
+	if err != nil {
+		t.Fatalf("PDF: %v", err)
+	}
+	if doc.Title != "Quantum Notes" {
+		t.Errorf("Title = %q, want %q", doc.Title, "Quantum Notes")
+	}
+
+	want := []string{
+		"First line\nSecond line",
+		"Quantum bits!\nNext",
+		"",
+	}
+	if !reflect.DeepEqual(doc.Pages, want) {
+		t.Errorf("Pages = %q, want %q", doc.Pages, want)
+	}
+}
+
+func TestPDFInvalid(t *testing.T) {
+	for _, data := range [][]byte{
+		nil,
+		[]byte("not a pdf"),
+		buildPDF("Broken", "BT /F1 12 Tf (text) Tj ET")[:60],
+	} {
+		if _, err := PDF(data); err == nil {
+			t.Errorf("PDF(%q) returned no error", data)
+		}
+	}
+}
+
+func TestNormalizePDFText(t *testing.T) {
+	got := normalizePDFText("  a   b \n\n\t c\n   \n")
+	if got != "a b\nc" {
+		t.Errorf("normalizePDFText = %q, want %q", got, "a b\nc")
+	}
+}
@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/rocker15962/llm-web-assistant/packages/backend/models"
+	"github.com/rocker15962/llm-web-assistant/packages/backend/search"
+	"github.com/rocker15962/llm-web-assistant/packages/backend/store"
+	"github.com/rocker15962/llm-web-assistant/packages/backend/utils"
+)
+
+// errTurnNotFound 表示請求接續的回答不存在
+var errTurnNotFound = errors.New("找不到回答")
+
+// conversationStore 保存多輪對話的歷史，默認使用記憶體，啟動時由 SetConversationStore 替換
+var conversationStore store.ConversationStore = store.NewMemoryStore()
+
+// SetConversationStore 設置對話記錄儲存
+func SetConversationStore(s store.ConversationStore) {
+	conversationStore = s
+}
+
+// HandleListConversations 返回所有對話的摘要
+func HandleListConversations(c *gin.Context) {
+	utils.LogRequest("GET", "/api/conversations", nil)
+
+	conversations, err := conversationStore.ListConversations()
+	if err != nil {
+		utils.LogErrorDetails(err, "讀取對話列表失敗")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("讀取對話列表失敗: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ConversationsResponse{Conversations: conversations})
+}
+
+// HandleGetConversation 返回單一對話及其所有輪次
+func HandleGetConversation(c *gin.Context) {
+	id := c.Param("id")
+	utils.LogRequest("GET", "/api/conversations/"+id, nil)
+
+	conv, found, err := conversationStore.GetConversation(id)
+	if err != nil {
+		utils.LogErrorDetails(err, "讀取對話失敗")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("讀取對話失敗: %v", err),
+		})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": fmt.Sprintf("找不到對話: %s", id),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, conv)
+}
+
+// HandleDeleteConversation 刪除單一對話
+func HandleDeleteConversation(c *gin.Context) {
+	id := c.Param("id")
+	utils.LogRequest("DELETE", "/api/conversations/"+id, nil)
+
+	found, err := conversationStore.DeleteConversation(id)
+	if err != nil {
+		utils.LogErrorDetails(err, "刪除對話失敗")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("刪除對話失敗: %v", err),
+		})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": fmt.Sprintf("找不到對話: %s", id),
+		})
+		return
+	}
+	searchIndex.RemoveConversation(id)
+
+	c.JSON(http.StatusOK, gin.H{"deleted": 1})
+}
+
+// HandleDeleteConversations 刪除所有對話
+func HandleDeleteConversations(c *gin.Context) {
+	utils.LogRequest("DELETE", "/api/conversations", nil)
+
+	count, err := conversationStore.DeleteAllConversations()
+	if err != nil {
+		utils.LogErrorDetails(err, "刪除對話失敗")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("刪除對話失敗: %v", err),
+		})
+		return
+	}
+	searchIndex.Clear()
+
+	c.JSON(http.StatusOK, gin.H{"deleted": count})
+}
+
+// loadConversation 為請求填入對話歷史，未提供對話 ID 時建立新的對話
+func loadConversation(req *models.AskRequest) error {
+	if req.ConversationID == "" {
+		req.ConversationID = utils.GenerateID("conv")
+		return nil
+	}
+
+	turns, found, err := conversationStore.Turns(req.ConversationID)
+	if err != nil {
+		return err
+	}
+	if !found {
+		// 例如服務重啟後記錄已遺失，以相同 ID 開始新的對話，保留客戶端提供的歷史
+		utils.LogWarning("找不到對話 %s，將開始新的對話", req.ConversationID)
+		req.ParentID = ""
+		return nil
+	}
+
+	// 只重播要接續的分支
+	if req.ParentID == "" {
+		req.ParentID = store.LatestTurnID(turns)
+	} else if _, ok := store.FindTurn(turns, req.ParentID); !ok {
+		return fmt.Errorf("%w: %s", errTurnNotFound, req.ParentID)
+	}
+	turns = store.Branch(turns, req.ParentID)
+
+	// 已被摘要涵蓋的輪次不再重播
+	if req.HistorySummary != "" && req.SummarizedTurns > 0 {
+		if req.SummarizedTurns > len(turns) {
+			req.SummarizedTurns = len(turns)
+		}
+		turns = turns[req.SummarizedTurns:]
+	}
+
+	req.History = turns
+	utils.LogDebug("載入對話 %s，共 %d 輪", req.ConversationID, len(turns))
+	return nil
+}
+
+// saveConversationTurn 將本輪問答加入對話記錄，並在響應中填入回答 ID
+func saveConversationTurn(req models.AskRequest, resp *models.AskResponse) {
+	saveVariantTurn(req, resp, "")
+}
+
+// saveVariantTurn 保存回答，sourceID 為重新生成或編輯的來源回答 ID
+func saveVariantTurn(req models.AskRequest, resp *models.AskResponse, sourceID string) {
+	resp.AnswerID = utils.GenerateID("ans")
+	resp.ParentID = req.ParentID
+
+	// 保存原始請求以便重新生成，歷史相關欄位由分支重建；
+	// 頁面內容和截圖以雜湊引用，同一對話中相同的內容只保存一次；
+	// HTML 在檢查請求時已提取到頁面內容中，不需要另外保存
+	stored := req
+	stored.ConversationID = ""
+	stored.History = nil
+	stored.HistorySummary = ""
+	stored.SummarizedTurns = 0
+	stored.PreviousResponseID = ""
+	stored.ParentID = ""
+	stored.Screenshot = ""
+	stored.HTML = ""
+	stored.PageContent = nil
+
+	turn := models.ConversationTurn{
+		ID:         resp.AnswerID,
+		ParentID:   req.ParentID,
+		SourceID:   sourceID,
+		Request:    &stored,
+		Question:   req.Question,
+		Answer:     resp.Answer,
+		URL:        req.URL,
+		Title:      req.Title,
+		Usage:      resp.Usage,
+		Provider:   resp.Provider,
+		Model:      resp.Model,
+		ResponseID: resp.ResponseID,
+		CreatedAt:  time.Now(),
+	}
+	if !req.PageContent.IsEmpty() {
+		turn.PageRef = req.PageContent.Hash()
+	}
+	if req.Screenshot != "" {
+		sum := sha256.Sum256([]byte(req.Screenshot))
+		turn.ScreenshotRef = hex.EncodeToString(sum[:])
+	}
+	if err := conversationStore.AppendTurn(req.ConversationID, turn); err != nil {
+		utils.LogErrorDetails(err, "保存對話記錄失敗")
+		return
+	}
+	if turn.PageRef != "" {
+		if err := conversationStore.PutPage(req.ConversationID, turn.PageRef, *req.PageContent); err != nil {
+			utils.LogErrorDetails(err, "保存頁面內容失敗")
+		}
+	}
+	if turn.ScreenshotRef != "" {
+		if err := conversationStore.PutScreenshot(req.ConversationID, turn.ScreenshotRef, req.Screenshot); err != nil {
+			utils.LogErrorDetails(err, "保存截圖失敗")
+		}
+	}
+	searchIndex.Add(search.DocumentFor(req.ConversationID, turn))
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/rocker15962/llm-web-assistant/packages/backend/export"
+	"github.com/rocker15962/llm-web-assistant/packages/backend/models"
+	"github.com/rocker15962/llm-web-assistant/packages/backend/store"
+	"github.com/rocker15962/llm-web-assistant/packages/backend/utils"
+)
+
+// HandleExport 將問答輪次匯出為 Markdown、JSON 或 HTML 文件
+func HandleExport(c *gin.Context) {
+	format := c.DefaultQuery("format", export.FormatMarkdown)
+	utils.LogRequest("POST", "/api/export", map[string]string{"format": format})
+
+	var req models.ExportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.LogError("無效的請求格式: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("無效的請求格式: %v", err),
+		})
+		return
+	}
+
+	// 未提供輪次時匯出已保存對話中最新的分支
+	if len(req.Turns) == 0 && req.ConversationID != "" {
+		conv, found, err := conversationStore.GetConversation(req.ConversationID)
+		if err != nil {
+			utils.LogErrorDetails(err, "讀取對話失敗")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": fmt.Sprintf("讀取對話失敗: %v", err),
+			})
+			return
+		}
+		if !found {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": fmt.Sprintf("找不到對話: %s", req.ConversationID),
+			})
+			return
+		}
+
+		req.Turns = store.Branch(conv.Turns, store.LatestTurnID(conv.Turns))
+		if req.Title == "" {
+			req.Title = conv.Title
+		}
+		if req.URL == "" {
+			req.URL = conv.URL
+		}
+	}
+
+	if len(req.Turns) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "沒有可匯出的問答",
+		})
+		return
+	}
+
+	data, contentType, filename, err := export.Render(export.NewDocument(req.Title, req.URL, req.Turns), format)
+	if err != nil {
+		utils.LogError("匯出對話失敗: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("%v", err),
+		})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Data(http.StatusOK, contentType, data)
+}
@@ -1,12 +1,15 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/rocker15962/llm-web-assistant/packages/backend/config"
 	"github.com/rocker15962/llm-web-assistant/packages/backend/models"
 	"github.com/rocker15962/llm-web-assistant/packages/backend/utils"
 )
@@ -20,6 +23,14 @@ func HandleHealth(c *gin.Context) {
 	})
 }
 
+// HandleModels 返回允許選擇的模型及其能力
+func HandleModels(c *gin.Context) {
+	utils.LogRequest("GET", "/api/models", nil)
+	c.JSON(http.StatusOK, models.ModelsResponse{
+		Models: utils.ListModels(config.LoadConfig()),
+	})
+}
+
 // HandleAsk 處理 LLM 問答請求
 func HandleAsk(c *gin.Context) {
 	startTime := time.Now()
@@ -27,6 +38,56 @@ func HandleAsk(c *gin.Context) {
 	// 記錄請求
 	utils.LogRequest("POST", "/api/ask", nil)
 
+	// 解析並檢查請求
+	req, ok := bindAskRequest(c)
+	if !ok {
+		return
+	}
+
+	// 使用 GenerateResponse 函數，客戶端斷線時取消上游請求
+	response, err := utils.GenerateResponse(c.Request.Context(), req)
+
+	if err != nil {
+		writeGenerateError(c, err, startTime)
+		return
+	}
+
+	// 記錄響應詳情
+	utils.LogLLMResponse(
+		response.Usage.PromptTokens,
+		response.Usage.CompletionTokens,
+		response.Usage.TotalTokens,
+		time.Since(startTime),
+	)
+
+	// 保存本輪問答
+	saveConversationTurn(req, &response)
+
+	// 返回回應
+	c.JSON(http.StatusOK, response)
+
+	// 記錄響應時間
+	utils.LogResponse("/api/ask", http.StatusOK, time.Since(startTime))
+}
+
+// writeGenerateError 寫入生成回答失敗的錯誤響應，客戶端已斷開時只記錄日誌
+func writeGenerateError(c *gin.Context, err error, startTime time.Time) {
+	if c.Request.Context().Err() != nil || errors.Is(err, context.Canceled) {
+		utils.LogInfo("客戶端已斷開連線，已取消生成，耗時: %v", time.Since(startTime))
+		return
+	}
+
+	utils.LogErrorDetails(err, "生成回答時出錯")
+
+	// 返回詳細的錯誤信息
+	c.JSON(http.StatusInternalServerError, gin.H{
+		"error":  fmt.Sprintf("%v", err),
+		"detail": "請檢查日誌獲取更多信息",
+	})
+}
+
+// bindAskRequest 解析並檢查問答請求，失敗時寫入錯誤響應並返回 false
+func bindAskRequest(c *gin.Context) (models.AskRequest, bool) {
 	// 解析請求
 	var req models.AskRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -34,12 +95,46 @@ func HandleAsk(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": fmt.Sprintf("無效的請求格式: %v", err),
 		})
-		return
+		return req, false
+	}
+
+	return checkAskRequest(c, req)
+}
+
+// checkAskRequest 檢查問答請求並載入對話歷史，失敗時寫入錯誤響應並返回 false
+func checkAskRequest(c *gin.Context, req models.AskRequest) (models.AskRequest, bool) {
+	req, status, err := prepareAskRequest(req)
+	if err != nil {
+		c.JSON(status, gin.H{
+			"error": fmt.Sprintf("%v", err),
+		})
+		return req, false
+	}
+	return req, true
+}
+
+// prepareAskRequest 檢查問答請求、載入對話歷史並提取 HTML 正文，HTTP 和 WebSocket 入口共用
+// 失敗時返回錯誤對應的 HTTP 狀態碼
+func prepareAskRequest(req models.AskRequest) (models.AskRequest, int, error) {
+	// 檢查請求內容
+	if err := validateAskRequest(req); err != nil {
+		utils.LogError("無效的請求: %v", err)
+		return req, http.StatusBadRequest, err
+	}
+
+	// 載入多輪對話的歷史
+	if err := loadConversation(&req); err != nil {
+		if errors.Is(err, errTurnNotFound) {
+			utils.LogError("無效的請求: %v", err)
+			return req, http.StatusBadRequest, err
+		}
+		utils.LogErrorDetails(err, "載入對話記錄失敗")
+		return req, http.StatusInternalServerError, fmt.Errorf("載入對話記錄失敗: %w", err)
 	}
 
 	// 記錄請求詳情
 	hasScreenshot := req.Screenshot != ""
-	hasPageContent := req.PageContent != ""
+	hasPageContent := !req.PageContent.IsEmpty()
 
 	utils.LogLLMRequest(
 		req.Question,
@@ -55,34 +150,31 @@ func HandleAsk(c *gin.Context) {
 		utils.LogDebug("截圖大小: %s", utils.FormatBytes(len(req.Screenshot)))
 	}
 	if hasPageContent {
-		utils.LogDebug("頁面內容大小: %s", utils.FormatBytes(len(req.PageContent)))
+		utils.LogDebug("頁面內容大小: %s", utils.FormatBytes(req.PageContent.Size()))
+	}
+	if req.HTML != "" {
+		utils.LogDebug("頁面 HTML 大小: %s", utils.FormatBytes(len(req.HTML)))
 	}
 
-	// 使用 GenerateResponse 函數
-	response, err := utils.GenerateResponse(req)
-
-	if err != nil {
-		utils.LogErrorDetails(err, "生成回答時出錯")
+	// 提前提取 HTML 正文，生成回答和保存對話時都使用提取後的頁面內容
+	return utils.ExtractPageHTML(req), http.StatusOK, nil
+}
 
-		// 返回詳細的錯誤信息
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":  fmt.Sprintf("%v", err),
-			"detail": "請檢查日誌獲取更多信息",
-		})
-		return
+// validateAskRequest 檢查問答請求，所有問答入口（HTTP、串流、WebSocket）共用
+func validateAskRequest(req models.AskRequest) error {
+	// 檢查請求的模型是否在允許列表中
+	if _, err := utils.ResolveModel(config.LoadConfig(), req.Model); err != nil {
+		return err
 	}
 
-	// 記錄響應詳情
-	utils.LogLLMResponse(
-		response.Usage.PromptTokens,
-		response.Usage.CompletionTokens,
-		response.Usage.TotalTokens,
-		time.Since(startTime),
-	)
+	if err := req.PageContent.Validate(); err != nil {
+		return err
+	}
 
-	// 返回回應
-	c.JSON(http.StatusOK, response)
+	if len(req.HTML) > config.GetMaxHTMLSize() {
+		return fmt.Errorf("頁面 HTML 過大: %s，上限為 %s",
+			utils.FormatBytes(len(req.HTML)), utils.FormatBytes(config.GetMaxHTMLSize()))
+	}
 
-	// 記錄響應時間
-	utils.LogResponse("/api/ask", http.StatusOK, time.Since(startTime))
+	return nil
 }
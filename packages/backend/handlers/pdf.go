@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/rocker15962/llm-web-assistant/packages/backend/config"
+	"github.com/rocker15962/llm-web-assistant/packages/backend/extract"
+	"github.com/rocker15962/llm-web-assistant/packages/backend/models"
+	"github.com/rocker15962/llm-web-assistant/packages/backend/utils"
+)
+
+// errPDFTooLarge PDF 超過配置的大小上限
+var errPDFTooLarge = errors.New("PDF 檔案過大")
+
+// HandleAskPDF 處理 PDF 文件問答請求
+// 接受 multipart/form-data（file 欄位為 PDF，其餘欄位同問答請求），
+// 或 JSON（pdf 欄位為 base64 編碼的 PDF）
+func HandleAskPDF(c *gin.Context) {
+	startTime := time.Now()
+
+	utils.LogRequest("POST", "/api/pdf/ask", nil)
+
+	maxSize := config.LoadConfig().PDFMaxSize
+	req, data, fileName, err := bindPDFRequest(c, maxSize)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, errPDFTooLarge) {
+			status = http.StatusRequestEntityTooLarge
+			err = fmt.Errorf("%w，上限為 %s", err, utils.FormatBytes(maxSize))
+		}
+		utils.LogError("無效的 PDF 請求: %v", err)
+		c.JSON(status, gin.H{
+			"error": fmt.Sprintf("%v", err),
+		})
+		return
+	}
+	utils.LogDebug("PDF 大小: %s", utils.FormatBytes(len(data)))
+
+	doc, err := extract.PDF(data)
+	if err != nil {
+		utils.LogError("解析 PDF 失敗: %v", err)
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error": fmt.Sprintf("%v", err),
+		})
+		return
+	}
+
+	page := &models.PageContent{Version: models.PageContentVersion, Pages: doc.Pages}
+	if page.IsEmpty() {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error": "PDF 中沒有可提取的文字，可能是掃描檔或圖片",
+		})
+		return
+	}
+	utils.LogDebug("已從 PDF 提取 %d 頁文字，共 %s", len(doc.Pages), utils.FormatBytes(page.Size()))
+
+	req.PageContent = page
+	req.HTML = ""
+	if req.Title == "" {
+		req.Title = doc.Title
+	}
+	if req.Title == "" {
+		req.Title = fileName
+	}
+
+	req, ok := checkAskRequest(c, req)
+	if !ok {
+		return
+	}
+
+	response, err := utils.GenerateResponse(c.Request.Context(), req)
+	if err != nil {
+		writeGenerateError(c, err, startTime)
+		return
+	}
+
+	utils.LogLLMResponse(
+		response.Usage.PromptTokens,
+		response.Usage.CompletionTokens,
+		response.Usage.TotalTokens,
+		time.Since(startTime),
+	)
+
+	saveConversationTurn(req, &response)
+
+	c.JSON(http.StatusOK, response)
+
+	utils.LogResponse("/api/pdf/ask", http.StatusOK, time.Since(startTime))
+}
+
+// bindPDFRequest 解析 multipart 或 JSON 格式的 PDF 問答請求
+// 返回問答請求、PDF 內容和檔案名稱
+func bindPDFRequest(c *gin.Context, maxSize int) (models.AskRequest, []byte, string, error) {
+	// base64 編碼約增加三分之一，另保留表單欄位的空間
+	limit := int64(maxSize)*4/3 + 1<<20
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+
+	var req models.AskRequest
+	var data []byte
+	var fileName string
+
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		header, err := c.FormFile("file")
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				return req, nil, "", errPDFTooLarge
+			}
+			return req, nil, "", fmt.Errorf("缺少 PDF 檔案: %w", err)
+		}
+		if header.Size > int64(maxSize) {
+			return req, nil, "", errPDFTooLarge
+		}
+
+		file, err := header.Open()
+		if err != nil {
+			return req, nil, "", fmt.Errorf("讀取 PDF 檔案失敗: %w", err)
+		}
+		defer file.Close()
+		if data, err = io.ReadAll(file); err != nil {
+			return req, nil, "", fmt.Errorf("讀取 PDF 檔案失敗: %w", err)
+		}
+
+		fileName = header.Filename
+		req = models.AskRequest{
+			Question:       c.PostForm("question"),
+			URL:            c.PostForm("url"),
+			Title:          c.PostForm("title"),
+			IsSimple:       c.PostForm("isSimple") == "true",
+			MapReduce:      c.PostForm("mapReduce") == "true",
+			Model:          c.PostForm("model"),
+			ConversationID: c.PostForm("conversationId"),
+			ParentID:       c.PostForm("parentId"),
+		}
+	} else {
+		var body models.PDFAskRequest
+		if err := c.ShouldBindJSON(&body); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				return req, nil, "", errPDFTooLarge
+			}
+			return req, nil, "", fmt.Errorf("無效的請求格式: %w", err)
+		}
+
+		// 接受 data:application/pdf;base64,... 格式
+		encoded := body.PDF
+		if _, after, ok := strings.Cut(encoded, ";base64,"); ok && strings.HasPrefix(encoded, "data:") {
+			encoded = after
+		}
+		if base64.StdEncoding.DecodedLen(len(encoded)) > maxSize+2 {
+			return req, nil, "", errPDFTooLarge
+		}
+		decoded, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return req, nil, "", fmt.Errorf("PDF 不是有效的 base64 編碼: %w", err)
+		}
+
+		data, fileName, req = decoded, body.FileName, body.AskRequest
+	}
+
+	if len(data) > maxSize {
+		return req, nil, "", errPDFTooLarge
+	}
+	if !bytes.HasPrefix(bytes.TrimLeft(data, "\x00\t\r\n "), []byte("%PDF-")) {
+		return req, nil, "", errors.New("上傳的檔案不是 PDF")
+	}
+	return req, data, fileName, nil
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/rocker15962/llm-web-assistant/packages/backend/models"
+	"github.com/rocker15962/llm-web-assistant/packages/backend/search"
+	"github.com/rocker15962/llm-web-assistant/packages/backend/utils"
+)
+
+// 搜索結果數量限制
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// searchIndex 保存所有問答的全文索引，啟動時由 SetSearchIndex 替換為從儲存重建的索引
+var searchIndex = search.NewIndex()
+
+// SetSearchIndex 設置全文索引
+func SetSearchIndex(idx *search.Index) {
+	searchIndex = idx
+}
+
+// HandleSearch 搜索過去的問題和回答
+func HandleSearch(c *gin.Context) {
+	query := strings.TrimSpace(c.Query("q"))
+	utils.LogRequest("GET", "/api/search", map[string]string{"q": query})
+
+	if query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "搜索關鍵字不能為空",
+		})
+		return
+	}
+
+	limit := defaultSearchLimit
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "無效的 limit 參數",
+			})
+			return
+		}
+		limit = parsed
+	}
+	if limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+
+	c.JSON(http.StatusOK, models.SearchResponse{
+		Query: query,
+		Hits:  searchIndex.Search(query, limit),
+	})
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/rocker15962/llm-web-assistant/packages/backend/utils"
+)
+
+// HandleAskStream 以 Server-Sent Events 串流返回 LLM 回答
+//
+// 事件類型：
+//   - delta: {"delta": "..."} 回答文字片段
+//   - web_search: {"status": "in_progress|searching|completed"} 網絡搜索進度
+//   - done: AskResponse，包含完整回答與 token 使用量
+//   - error: {"error": "..."} 生成失敗
+func HandleAskStream(c *gin.Context) {
+	startTime := time.Now()
+
+	// 記錄請求
+	utils.LogRequest("POST", "/api/ask/stream", nil)
+
+	// 解析並檢查請求
+	req, ok := bindAskRequest(c)
+	if !ok {
+		return
+	}
+
+	// 設置 SSE 響應頭
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	// 客戶端斷線時 request context 會被取消，上游請求也隨之中止
+	ctx := c.Request.Context()
+
+	response, err := utils.StreamResponse(ctx, req, func(event utils.StreamEvent) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		switch event.Type {
+		case utils.StreamEventDelta, utils.StreamEventWebSearch:
+			c.SSEvent(event.Type, event)
+			c.Writer.Flush()
+		}
+		return nil
+	})
+
+	if err != nil {
+		if ctx.Err() != nil || errors.Is(err, context.Canceled) {
+			utils.LogInfo("客戶端已斷開串流連線，耗時: %v", time.Since(startTime))
+			return
+		}
+
+		utils.LogErrorDetails(err, "串流生成回答時出錯")
+		c.SSEvent("error", gin.H{
+			"error": fmt.Sprintf("%v", err),
+		})
+		c.Writer.Flush()
+		return
+	}
+
+	// 記錄響應詳情
+	utils.LogLLMResponse(
+		response.Usage.PromptTokens,
+		response.Usage.CompletionTokens,
+		response.Usage.TotalTokens,
+		time.Since(startTime),
+	)
+
+	// 保存本輪問答
+	saveConversationTurn(req, &response)
+
+	c.SSEvent(utils.StreamEventDone, response)
+	c.Writer.Flush()
+
+	// 記錄響應時間
+	utils.LogResponse("/api/ask/stream", http.StatusOK, time.Since(startTime))
+}
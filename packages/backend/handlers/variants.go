@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/rocker15962/llm-web-assistant/packages/backend/models"
+	"github.com/rocker15962/llm-web-assistant/packages/backend/store"
+	"github.com/rocker15962/llm-web-assistant/packages/backend/utils"
+)
+
+// HandleRegenerate 以原始請求重新生成回答，可選擇不同的模型或簡單/詳細模式
+// 新的回答與原回答位於同一位置，原回答保持不變
+func HandleRegenerate(c *gin.Context) {
+	handleVariant(c, "regenerate")
+}
+
+// HandleEditTurn 以編輯後的問題生成新的分支，原來的問答保持不變
+func HandleEditTurn(c *gin.Context) {
+	handleVariant(c, "edit")
+}
+
+// HandleListVariants 返回與指定回答位於同一位置的所有版本
+func HandleListVariants(c *gin.Context) {
+	id, turnID := c.Param("id"), c.Param("turnId")
+	utils.LogRequest("GET", "/api/conversations/"+id+"/turns/"+turnID+"/variants", nil)
+
+	turns, ok := findVariantSource(c, id, turnID)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, models.VariantsResponse{Variants: store.Variants(turns, turnID)})
+}
+
+// handleVariant 處理重新生成與編輯請求
+func handleVariant(c *gin.Context, action string) {
+	startTime := time.Now()
+	id, turnID := c.Param("id"), c.Param("turnId")
+	path := "/api/conversations/" + id + "/turns/" + turnID + "/" + action
+	utils.LogRequest("POST", path, nil)
+
+	// 重新生成時請求體可以為空
+	var body models.VariantRequest
+	if err := c.ShouldBindJSON(&body); err != nil && !errors.Is(err, io.EOF) {
+		utils.LogError("無效的請求格式: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("無效的請求格式: %v", err),
+		})
+		return
+	}
+	if action == "edit" && strings.TrimSpace(body.Question) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "編輯後的問題不能為空",
+		})
+		return
+	}
+
+	turns, ok := findVariantSource(c, id, turnID)
+	if !ok {
+		return
+	}
+	source, _ := store.FindTurn(turns, turnID)
+	if source.Request == nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error": fmt.Sprintf("回答 %s 沒有保存原始請求，無法重新生成", turnID),
+		})
+		return
+	}
+
+	// 以原始請求為基礎，接在原回答的上一輪之後
+	req := *source.Request
+	req.ConversationID = id
+	req.ParentID = source.ParentID
+	req.History = store.Branch(turns, source.ParentID)
+	if action == "edit" {
+		req.Question = body.Question
+	}
+	if body.Model != "" {
+		req.Model = body.Model
+	}
+	if body.IsSimple != nil {
+		req.IsSimple = *body.IsSimple
+	}
+	if !restoreAttachments(c, id, source, &req) {
+		return
+	}
+
+	if err := validateAskRequest(req); err != nil {
+		utils.LogError("無效的請求: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("%v", err),
+		})
+		return
+	}
+
+	response, err := utils.GenerateResponse(c.Request.Context(), req)
+	if err != nil {
+		writeGenerateError(c, err, startTime)
+		return
+	}
+
+	utils.LogLLMResponse(
+		response.Usage.PromptTokens,
+		response.Usage.CompletionTokens,
+		response.Usage.TotalTokens,
+		time.Since(startTime),
+	)
+
+	saveVariantTurn(req, &response, source.ID)
+
+	c.JSON(http.StatusOK, response)
+
+	utils.LogResponse(path, http.StatusOK, time.Since(startTime))
+}
+
+// restoreAttachments 載入原始請求的頁面內容和截圖，失敗時寫入錯誤響應並返回 false
+// 缺少任何一項時不重新生成，避免在不同的輸入下產生看似相同請求的回答
+func restoreAttachments(c *gin.Context, conversationID string, source models.ConversationTurn, req *models.AskRequest) bool {
+	if source.PageRef != "" {
+		page, found, err := conversationStore.Page(conversationID, source.PageRef)
+		if err != nil {
+			utils.LogErrorDetails(err, "讀取頁面內容失敗")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": fmt.Sprintf("讀取頁面內容失敗: %v", err),
+			})
+			return false
+		}
+		if !found {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"error": fmt.Sprintf("找不到回答 %s 的頁面內容，無法重新生成", source.ID),
+			})
+			return false
+		}
+		req.PageContent = &page
+	}
+
+	if source.ScreenshotRef != "" {
+		screenshot, found, err := conversationStore.Screenshot(conversationID, source.ScreenshotRef)
+		if err != nil {
+			utils.LogErrorDetails(err, "讀取截圖失敗")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": fmt.Sprintf("讀取截圖失敗: %v", err),
+			})
+			return false
+		}
+		if !found {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"error": fmt.Sprintf("找不到回答 %s 的截圖，無法重新生成", source.ID),
+			})
+			return false
+		}
+		req.Screenshot = screenshot
+	}
+
+	return true
+}
+
+// findVariantSource 讀取對話並確認回答存在，失敗時寫入錯誤響應並返回 false
+func findVariantSource(c *gin.Context, conversationID, turnID string) ([]models.ConversationTurn, bool) {
+	turns, found, err := conversationStore.Turns(conversationID)
+	if err != nil {
+		utils.LogErrorDetails(err, "讀取對話失敗")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("讀取對話失敗: %v", err),
+		})
+		return nil, false
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": fmt.Sprintf("找不到對話: %s", conversationID),
+		})
+		return nil, false
+	}
+	if _, ok := store.FindTurn(turns, turnID); !ok {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": fmt.Sprintf("%v: %s", errTurnNotFound, turnID),
+		})
+		return nil, false
+	}
+	return turns, true
+}
@@ -0,0 +1,307 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+
+	"github.com/rocker15962/llm-web-assistant/packages/backend/config"
+	"github.com/rocker15962/llm-web-assistant/packages/backend/models"
+	"github.com/rocker15962/llm-web-assistant/packages/backend/utils"
+)
+
+// WebSocket 連線參數
+const (
+	wsWriteWait    = 10 * time.Second
+	wsPongWait     = 60 * time.Second
+	wsPingInterval = (wsPongWait * 9) / 10
+	// quotaWarningRatio 會話 token 用量達到上限的比例時發出警告
+	quotaWarningRatio = 0.8
+	// wsMaxInFlight 每個會話同時進行的生成數上限
+	wsMaxInFlight = 4
+)
+
+// wsUpgrader 只允許瀏覽器擴展和 WS_ALLOWED_ORIGINS 中的來源
+// 沒有 Origin 的連線來自非瀏覽器客戶端，不受跨來源限制
+var wsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  4096,
+	WriteBufferSize: 4096,
+	CheckOrigin: func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		return origin == "" || config.LoadConfig().OriginAllowed(origin)
+	},
+}
+
+// wsSession 表示一個 WebSocket 會話
+type wsSession struct {
+	id   string
+	conn *websocket.Conn
+	cfg  config.Config
+
+	writeMu sync.Mutex // gorilla/websocket 不允許並發寫入
+
+	mu          sync.Mutex
+	inFlight    map[string]context.CancelFunc
+	usedTokens  int
+	quotaWarned bool
+
+	// reservedTokens 進行中生成預留的估計用量，避免同時進行的問題合計超過上限
+	reservedTokens int
+}
+
+// HandleWebSocket 處理側邊欄的 WebSocket 會話
+// 客戶端可以在同一連線中提出多個問題、接收串流回答並取消進行中的生成
+// WS_SESSION_TOKEN_LIMIT 只限制單一 WebSocket 會話的用量，HTTP 問答不受此限制
+func HandleWebSocket(c *gin.Context) {
+	utils.LogRequest("GET", "/api/ws", nil)
+
+	conn, err := wsUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		utils.LogErrorDetails(err, "升級 WebSocket 連線失敗")
+		return
+	}
+
+	session := &wsSession{
+		id:       utils.GenerateID("ws"),
+		conn:     conn,
+		cfg:      config.LoadConfig(),
+		inFlight: map[string]context.CancelFunc{},
+	}
+	utils.LogInfo("WebSocket 會話已建立: %s", session.id)
+
+	session.run(c.Request.Context())
+	utils.LogInfo("WebSocket 會話已結束: %s", session.id)
+}
+
+// run 處理會話的讀取循環，返回時關閉連線並取消所有進行中的生成
+func (s *wsSession) run(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer func() {
+		cancel()
+		s.conn.Close()
+	}()
+
+	// 限制單一消息大小，與 HTTP 請求的內容限制一致
+	s.conn.SetReadLimit(int64(config.GetMaxContentLength() + config.GetMaxHTMLSize() + config.GetMaxImageSize()))
+	s.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	s.conn.SetPongHandler(func(string) error {
+		return s.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	go s.keepAlive(ctx)
+
+	s.send(models.WSMessage{Type: models.WSTypeReady, SessionID: s.id})
+
+	for {
+		var msg models.WSMessage
+		if err := s.conn.ReadJSON(&msg); err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				utils.LogWarning("WebSocket 讀取失敗 (%s): %v", s.id, err)
+			}
+			return
+		}
+		s.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+
+		switch msg.Type {
+		case models.WSTypeAsk:
+			s.startAsk(ctx, msg)
+		case models.WSTypeCancel:
+			s.cancel(msg.ID)
+		case models.WSTypePing:
+			s.send(models.WSMessage{Type: models.WSTypePong, ID: msg.ID})
+		default:
+			s.send(models.WSMessage{
+				Type:  models.WSTypeError,
+				ID:    msg.ID,
+				Error: fmt.Sprintf("未知的消息類型: %s", msg.Type),
+			})
+		}
+	}
+}
+
+// keepAlive 定期發送 ping 以偵測斷線
+func (s *wsSession) keepAlive(ctx context.Context) {
+	ticker := time.NewTicker(wsPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.writeMu.Lock()
+			err := s.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait))
+			s.writeMu.Unlock()
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+// startAsk 檢查問題並在背景開始串流生成
+func (s *wsSession) startAsk(ctx context.Context, msg models.WSMessage) {
+	if msg.ID == "" {
+		msg.ID = utils.GenerateID("q")
+	}
+	if msg.Request == nil {
+		s.sendError(msg.ID, errors.New("缺少 request 欄位"))
+		return
+	}
+
+	req, _, err := prepareAskRequest(*msg.Request)
+	if err != nil {
+		s.sendError(msg.ID, err)
+		return
+	}
+
+	reserved := 0
+	if s.cfg.WSTokenLimit > 0 {
+		reserved = utils.EstimateAskTokens(s.cfg, req)
+	}
+
+	askCtx, cancel := context.WithCancel(ctx)
+	if err := s.admit(msg.ID, cancel, reserved); err != nil {
+		cancel()
+		s.sendError(msg.ID, err)
+		return
+	}
+
+	go s.ask(askCtx, msg.ID, req, reserved)
+}
+
+// admit 登記進行中的生成並預留估計的 token 用量
+// 檢查和預留在同一個鎖內完成，同時送出的多個問題不會一起通過上限檢查
+func (s *wsSession) admit(id string, cancel context.CancelFunc, reserved int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.inFlight[id]; exists {
+		return fmt.Errorf("問題 %s 正在生成中", id)
+	}
+	if len(s.inFlight) >= wsMaxInFlight {
+		return fmt.Errorf("同時進行的問題已達上限 %d，請等待或取消進行中的問題", wsMaxInFlight)
+	}
+	if limit := s.cfg.WSTokenLimit; limit > 0 && s.usedTokens+s.reservedTokens+reserved > limit {
+		return fmt.Errorf("會話 token 用量將超過上限 %d（已使用 %d，進行中預留 %d，本次估計 %d）",
+			limit, s.usedTokens, s.reservedTokens, reserved)
+	}
+
+	s.inFlight[id] = cancel
+	s.reservedTokens += reserved
+	return nil
+}
+
+// ask 串流生成回答並推送給客戶端，結束時釋放預留的 token 用量
+func (s *wsSession) ask(ctx context.Context, id string, req models.AskRequest, reserved int) {
+	startTime := time.Now()
+	defer s.finish(id, reserved)
+
+	response, err := utils.StreamResponse(ctx, req, func(event utils.StreamEvent) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		switch event.Type {
+		case utils.StreamEventDelta:
+			return s.send(models.WSMessage{Type: models.WSTypeDelta, ID: id, Delta: event.Delta})
+		case utils.StreamEventWebSearch:
+			return s.send(models.WSMessage{Type: models.WSTypeWebSearch, ID: id, Status: event.Status})
+		}
+		return nil
+	})
+
+	if err != nil {
+		if ctx.Err() != nil {
+			utils.LogInfo("WebSocket 生成已取消: %s/%s", s.id, id)
+			s.send(models.WSMessage{Type: models.WSTypeCancelled, ID: id})
+			return
+		}
+		utils.LogErrorDetails(err, "WebSocket 生成回答時出錯")
+		s.sendError(id, err)
+		return
+	}
+
+	utils.LogLLMResponse(
+		response.Usage.PromptTokens,
+		response.Usage.CompletionTokens,
+		response.Usage.TotalTokens,
+		time.Since(startTime),
+	)
+
+	saveConversationTurn(req, &response)
+	s.send(models.WSMessage{Type: models.WSTypeDone, ID: id, Response: &response})
+	s.recordUsage(response.Usage)
+}
+
+// cancel 取消指定的進行中生成
+func (s *wsSession) cancel(id string) {
+	s.mu.Lock()
+	cancel, ok := s.inFlight[id]
+	s.mu.Unlock()
+
+	if !ok {
+		s.sendError(id, fmt.Errorf("沒有進行中的問題: %s", id))
+		return
+	}
+	cancel()
+}
+
+// finish 移除已結束的生成並釋放預留的 token 用量
+func (s *wsSession) finish(id string, reserved int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cancel, ok := s.inFlight[id]; ok {
+		cancel()
+		delete(s.inFlight, id)
+	}
+	s.reservedTokens -= reserved
+}
+
+// recordUsage 累計 token 用量並在接近上限時推送警告
+func (s *wsSession) recordUsage(usage models.TokenUsage) {
+	if s.cfg.WSTokenLimit <= 0 {
+		return
+	}
+
+	s.mu.Lock()
+	s.usedTokens += usage.TotalTokens
+	used := s.usedTokens
+	warn := !s.quotaWarned && float64(used) >= float64(s.cfg.WSTokenLimit)*quotaWarningRatio
+	if warn {
+		s.quotaWarned = true
+	}
+	s.mu.Unlock()
+
+	if warn {
+		s.send(models.WSMessage{
+			Type:    models.WSTypeQuotaWarning,
+			Message: fmt.Sprintf("會話已使用 %d / %d tokens", used, s.cfg.WSTokenLimit),
+			Usage:   &models.TokenUsage{TotalTokens: used},
+			Limit:   s.cfg.WSTokenLimit,
+		})
+	}
+}
+
+// send 序列化並發送消息
+func (s *wsSession) send(msg models.WSMessage) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	s.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+	if err := s.conn.WriteJSON(msg); err != nil {
+		utils.LogDebug("WebSocket 發送失敗 (%s): %v", s.id, err)
+		return err
+	}
+	return nil
+}
+
+// sendError 發送錯誤消息
+func (s *wsSession) sendError(id string, err error) {
+	s.send(models.WSMessage{Type: models.WSTypeError, ID: id, Error: err.Error()})
+}
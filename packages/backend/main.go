@@ -8,7 +8,10 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 
+	"github.com/rocker15962/llm-web-assistant/packages/backend/config"
 	"github.com/rocker15962/llm-web-assistant/packages/backend/handlers"
+	"github.com/rocker15962/llm-web-assistant/packages/backend/search"
+	"github.com/rocker15962/llm-web-assistant/packages/backend/store"
 	"github.com/rocker15962/llm-web-assistant/packages/backend/utils"
 )
 
@@ -36,15 +39,52 @@ func main() {
 	// 配置 CORS
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"},
-		AllowMethods:     []string{"GET", "POST", "OPTIONS"},
+		AllowMethods:     []string{"GET", "POST", "DELETE", "OPTIONS"},
 		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
 		ExposeHeaders:    []string{"Content-Length"},
 		AllowCredentials: true,
 	}))
 
+	// 開啟對話記錄儲存
+	conversationStore, err := store.Open(config.LoadConfig())
+	if err != nil {
+		utils.LogFatal("開啟對話記錄儲存失敗: %v", err)
+	}
+	defer conversationStore.Close()
+	handlers.SetConversationStore(conversationStore)
+
+	// 從對話記錄重建全文索引
+	searchIndex := search.NewIndex()
+	conversations, err := conversationStore.ListConversations()
+	if err != nil {
+		utils.LogFatal("建立搜索索引失敗: %v", err)
+	}
+	for _, conv := range conversations {
+		turns, _, err := conversationStore.Turns(conv.ID)
+		if err != nil {
+			utils.LogFatal("建立搜索索引失敗: %v", err)
+		}
+		searchIndex.AddConversation(conv.ID, turns)
+	}
+	utils.LogInfo("搜索索引已建立，共 %d 輪問答", searchIndex.Len())
+	handlers.SetSearchIndex(searchIndex)
+
 	// 設置路由
 	r.GET("/api/health", handlers.HandleHealth)
+	r.GET("/api/models", handlers.HandleModels)
 	r.POST("/api/ask", handlers.HandleAsk)
+	r.POST("/api/ask/stream", handlers.HandleAskStream)
+	r.POST("/api/pdf/ask", handlers.HandleAskPDF)
+	r.GET("/api/ws", handlers.HandleWebSocket)
+	r.GET("/api/conversations", handlers.HandleListConversations)
+	r.DELETE("/api/conversations", handlers.HandleDeleteConversations)
+	r.GET("/api/conversations/:id", handlers.HandleGetConversation)
+	r.DELETE("/api/conversations/:id", handlers.HandleDeleteConversation)
+	r.GET("/api/conversations/:id/turns/:turnId/variants", handlers.HandleListVariants)
+	r.POST("/api/conversations/:id/turns/:turnId/regenerate", handlers.HandleRegenerate)
+	r.POST("/api/conversations/:id/turns/:turnId/edit", handlers.HandleEditTurn)
+	r.POST("/api/export", handlers.HandleExport)
+	r.GET("/api/search", handlers.HandleSearch)
 
 	// 獲取端口
 	port := os.Getenv("PORT")
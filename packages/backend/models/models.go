@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 // LLMRequest 表示從前端發送的請求
 type LLMRequest struct {
 	Question     string `json:"question" binding:"required"`
@@ -19,9 +21,23 @@ type LLMResponse struct {
 }
 
 // OpenAIMessage 表示 OpenAI API 消息格式
+// Content 可以是字串，或是包含圖片時的 []OpenAIContentPart
 type OpenAIMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role    string      `json:"role"`
+	Content interface{} `json:"content"`
+}
+
+// OpenAIContentPart 表示 OpenAI API 多模態消息的內容片段
+type OpenAIContentPart struct {
+	Type     string          `json:"type"`
+	Text     string          `json:"text,omitempty"`
+	ImageURL *OpenAIImageURL `json:"image_url,omitempty"`
+}
+
+// OpenAIImageURL 表示 OpenAI API 的圖片 URL
+type OpenAIImageURL struct {
+	URL    string `json:"url"`
+	Detail string `json:"detail,omitempty"`
 }
 
 // OpenAITool 表示 OpenAI API 工具格式
@@ -50,6 +66,7 @@ type OpenAIResponse struct {
 			Role    string `json:"role"`
 			Content string `json:"content"`
 		} `json:"message"`
+		FinishReason string `json:"finish_reason"` // length 表示達到最大輸出 token 數
 	} `json:"choices"`
 	Usage struct {
 		PromptTokens     int `json:"prompt_tokens"`
@@ -63,10 +80,77 @@ type AskRequest struct {
 	Question     string `json:"question"`
 	URL          string `json:"url"`
 	Title        string `json:"title"`
-	PageContent  string `json:"pageContent"`
+	HTML         string `json:"html,omitempty"` // 可選，頁面原始 HTML，由服務端提取正文放入 pageContent.markdown
 	Screenshot   string `json:"screenshot"`
 	UseWebSearch bool   `json:"useWebSearch"`
 	IsSimple     bool   `json:"isSimple"`
+	MapReduce    bool   `json:"mapReduce,omitempty"` // 可選，頁面超出上下文時先分段摘錄再合併回答
+	Model        string `json:"model,omitempty"`     // 可選，必須在允許列表中
+
+	// PageContent 擴展提取的結構化頁面內容；也接受舊版以字串傳送的 JSON 或純文本
+	PageContent *PageContent `json:"pageContent,omitempty"`
+	// SelectedText 可選，使用者在頁面上選取的文字；問題中的「這個」「這段」等以選取的文字為準
+	// 未提供時使用 pageContent.selectedText
+	SelectedText string `json:"selectedText,omitempty"`
+	// SelectionContext 可選，選取文字所在的段落，幫助理解選取的內容
+	SelectionContext string `json:"selectionContext,omitempty"`
+	// PageNotes 分段摘錄頁面後合併的各段摘錄，僅在服務端內部傳遞給最後的回答
+	PageNotes string `json:"-"`
+	// ConversationID 可選，用於多輪對話；未提供時會建立新的對話
+	ConversationID string `json:"conversationId,omitempty"`
+	// History 先前的輪次，可由客戶端提供；對話已保存在服務端時以服務端記錄為準
+	// 使用摘要時只需包含摘要之後的輪次
+	History []ConversationTurn `json:"history,omitempty"`
+	// HistorySummary 可選，先前 AskResponse 返回的歷史摘要
+	HistorySummary string `json:"historySummary,omitempty"`
+	// SummarizedTurns 摘要涵蓋的最早輪次數量
+	SummarizedTurns int `json:"summarizedTurns,omitempty"`
+	// PreviousResponseID 可選，上一次 AskResponse 的 responseId；
+	// 由產生該回應的同一提供者和模型回答時只發送新問題並串接上游回應，否則回退為完整重播
+	PreviousResponseID string `json:"previousResponseId,omitempty"`
+	// ParentID 可選，要接續的回答 ID；未提供時接續對話中最新的回答
+	ParentID string `json:"parentId,omitempty"`
+}
+
+// ConversationTurn 定義了對話中的一輪問答
+// 重新生成或編輯問題產生的回答與原回答共用 ParentID，形成分支
+type ConversationTurn struct {
+	ID         string      `json:"id,omitempty"`       // 回答 ID
+	ParentID   string      `json:"parentId,omitempty"` // 接續的回答 ID，空字串表示第一輪
+	SourceID   string      `json:"sourceId,omitempty"` // 重新生成或編輯的來源回答 ID
+	Request    *AskRequest `json:"request,omitempty"`  // 產生此回答的請求，用於重新生成
+	Question   string      `json:"question"`
+	Answer     string      `json:"answer"`
+	URL        string      `json:"url,omitempty"`
+	Title      string      `json:"title,omitempty"`
+	Usage      TokenUsage  `json:"usage"`
+	Provider   string      `json:"provider,omitempty"`
+	Model      string      `json:"model,omitempty"`
+	ResponseID string      `json:"responseId,omitempty"`
+	CreatedAt  time.Time   `json:"createdAt"`
+
+	// PageRef 請求頁面內容的雜湊；頁面內容在對話中另外保存一次，重新生成時載入
+	PageRef string `json:"pageRef,omitempty"`
+
+	// ScreenshotRef 請求截圖的雜湊；截圖與頁面內容一樣另外保存，重新生成時載入
+	ScreenshotRef string `json:"screenshotRef,omitempty"`
+}
+
+// Conversation 定義了一段多輪對話
+type Conversation struct {
+	ID        string             `json:"id"`
+	URL       string             `json:"url,omitempty"`   // 第一輪問答時的頁面 URL
+	Title     string             `json:"title,omitempty"` // 第一輪問答時的頁面標題
+	TurnCount int                `json:"turnCount"`
+	Usage     TokenUsage         `json:"usage"` // 所有輪次的 token 使用量總和
+	CreatedAt time.Time          `json:"createdAt"`
+	UpdatedAt time.Time          `json:"updatedAt"`
+	Turns     []ConversationTurn `json:"turns,omitempty"`
+}
+
+// ConversationsResponse 定義了對話列表的響應格式
+type ConversationsResponse struct {
+	Conversations []Conversation `json:"conversations"`
 }
 
 // TokenUsage 定義了 token 使用量
@@ -78,6 +162,133 @@ type TokenUsage struct {
 
 // AskResponse 定義了回答的響應格式
 type AskResponse struct {
-	Answer string     `json:"answer"`
-	Usage  TokenUsage `json:"usage"`
+	Answer   string     `json:"answer"`
+	Usage    TokenUsage `json:"usage"`
+	Provider string     `json:"provider,omitempty"` // 實際回答的提供者
+	Model    string     `json:"model,omitempty"`    // 實際使用的模型
+
+	ConversationID string `json:"conversationId,omitempty"`
+	// ResponseID 上游回應的 ID，可在後續請求中作為 previousResponseId
+	ResponseID string `json:"responseId,omitempty"`
+	// HistorySummary 本次壓縮產生的歷史摘要，涵蓋最早的 SummarizedTurns 輪，
+	// 客戶端可在後續請求中連同 summarizedTurns 一併送回
+	HistorySummary  string `json:"historySummary,omitempty"`
+	SummarizedTurns int    `json:"summarizedTurns,omitempty"`
+
+	AnswerID string `json:"answerId,omitempty"` // 保存後的回答 ID
+	ParentID string `json:"parentId,omitempty"` // 接續的回答 ID
+
+	// Truncated 回答因達到最大輸出 token 數等限制而不完整
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// VariantRequest 定義了重新生成或編輯問題的請求
+type VariantRequest struct {
+	Question string `json:"question"`           // 編輯後的問題，重新生成時忽略
+	Model    string `json:"model,omitempty"`    // 可選，未提供時使用原請求的模型
+	IsSimple *bool  `json:"isSimple,omitempty"` // 可選，未提供時沿用原請求的設置
+}
+
+// VariantsResponse 定義了同一位置所有回答版本的響應格式
+type VariantsResponse struct {
+	Variants []ConversationTurn `json:"variants"`
+}
+
+// PDFAskRequest 定義了以 JSON 上傳 PDF 的問答請求
+type PDFAskRequest struct {
+	AskRequest
+	PDF      string `json:"pdf"` // base64 編碼的 PDF 檔案，也接受 data URL
+	FileName string `json:"fileName,omitempty"`
+}
+
+// ExportRequest 定義了匯出對話的請求
+// 提供 Turns 時匯出這些輪次，否則匯出 ConversationID 對應對話中最新的分支
+type ExportRequest struct {
+	Title          string             `json:"title"`
+	URL            string             `json:"url"`
+	Turns          []ConversationTurn `json:"turns"`
+	ConversationID string             `json:"conversationId,omitempty"`
+}
+
+// ExportDocument 定義了匯出的對話文件，也是 JSON 匯出的格式
+type ExportDocument struct {
+	Title      string       `json:"title"`
+	URL        string       `json:"url"`
+	ExportedAt time.Time    `json:"exportedAt"`
+	Turns      []ExportTurn `json:"turns"`
+	Usage      TokenUsage   `json:"usage"` // 所有輪次的 token 使用量總和
+}
+
+// ExportTurn 定義了匯出文件中的一輪問答
+type ExportTurn struct {
+	Question  string     `json:"question"`
+	Answer    string     `json:"answer"`
+	Usage     TokenUsage `json:"usage"`
+	Provider  string     `json:"provider,omitempty"`
+	Model     string     `json:"model,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+// SearchHit 定義了搜索結果中的一輪問答
+type SearchHit struct {
+	ConversationID string    `json:"conversationId"`
+	AnswerID       string    `json:"answerId,omitempty"`
+	Question       string    `json:"question"`
+	Title          string    `json:"title,omitempty"`
+	URL            string    `json:"url,omitempty"`
+	CreatedAt      time.Time `json:"createdAt"`
+	Score          float64   `json:"score"`
+	Snippet        string    `json:"snippet"` // 回答中命中位置附近的摘錄
+}
+
+// SearchResponse 定義了搜索的響應格式
+type SearchResponse struct {
+	Query string      `json:"query"`
+	Hits  []SearchHit `json:"hits"`
+}
+
+// ModelInfo 定義了可選模型及其能力
+type ModelInfo struct {
+	ID            string `json:"id"`
+	Provider      string `json:"provider"`
+	Model         string `json:"model"`
+	Vision        bool   `json:"vision"`
+	WebSearch     bool   `json:"webSearch"`
+	ContextWindow int    `json:"contextWindow"`
+	Default       bool   `json:"default"`
+}
+
+// ModelsResponse 定義了模型列表的響應格式
+type ModelsResponse struct {
+	Models []ModelInfo `json:"models"`
+}
+
+// WebSocket 消息類型
+const (
+	WSTypeAsk          = "ask"           // 客戶端：提出問題
+	WSTypeCancel       = "cancel"        // 客戶端：取消進行中的生成
+	WSTypePing         = "ping"          // 客戶端：保持連線
+	WSTypeReady        = "ready"         // 服務端：連線已建立
+	WSTypeDelta        = "delta"         // 服務端：回答文字片段
+	WSTypeWebSearch    = "web_search"    // 服務端：網絡搜索進度
+	WSTypeDone         = "done"          // 服務端：生成完成
+	WSTypeCancelled    = "cancelled"     // 服務端：生成已取消
+	WSTypeError        = "error"         // 服務端：錯誤
+	WSTypeQuotaWarning = "quota_warning" // 服務端：會話 token 用量接近上限
+	WSTypePong         = "pong"          // 服務端：回應 ping
+)
+
+// WSMessage 定義了 WebSocket 通道上的消息格式
+type WSMessage struct {
+	Type      string       `json:"type"`
+	ID        string       `json:"id,omitempty"` // 客戶端為每個問題指定的識別碼
+	SessionID string       `json:"sessionId,omitempty"`
+	Request   *AskRequest  `json:"request,omitempty"`
+	Response  *AskResponse `json:"response,omitempty"`
+	Delta     string       `json:"delta,omitempty"`
+	Status    string       `json:"status,omitempty"`
+	Error     string       `json:"error,omitempty"`
+	Message   string       `json:"message,omitempty"`
+	Usage     *TokenUsage  `json:"usage,omitempty"`
+	Limit     int          `json:"limit,omitempty"`
 }
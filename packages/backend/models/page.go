@@ -0,0 +1,274 @@
+package models
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// PageContentVersion 目前的頁面內容結構版本
+// 版本 1 為擴展早期以 JSON 字串送出的 headings、paragraphs、links、bodyText；
+// 版本 2 加入標題層級、列表、表格、meta 標籤、選取文字和語言
+const PageContentVersion = 2
+
+// PageContent 定義了擴展提取的結構化頁面內容
+type PageContent struct {
+	Version      int               `json:"version"`
+	Language     string            `json:"language,omitempty"` // BCP 47 語言標籤，例如 zh-TW
+	Meta         map[string]string `json:"meta,omitempty"`     // description、keywords、author 等 meta 標籤
+	Headings     []PageHeading     `json:"headings,omitempty"`
+	Paragraphs   []string          `json:"paragraphs,omitempty"`
+	Lists        []PageList        `json:"lists,omitempty"`
+	Tables       []PageTable       `json:"tables,omitempty"`
+	Links        []PageLink        `json:"links,omitempty"`
+	SelectedText string            `json:"selectedText,omitempty"`
+	BodyText     string            `json:"bodyText,omitempty"`
+	Markdown     string            `json:"markdown,omitempty"` // 服務端從原始 HTML 提取的正文
+	Pages        []string          `json:"pages,omitempty"`    // PDF 每頁的文字，索引 0 為第 1 頁
+}
+
+// PageHeading 定義了頁面標題，Level 為 1 到 6，0 表示未知（版本 1 只有文字）
+type PageHeading struct {
+	Level int    `json:"level,omitempty"`
+	Text  string `json:"text"`
+}
+
+// PageList 定義了頁面中的列表
+type PageList struct {
+	Ordered bool     `json:"ordered,omitempty"`
+	Items   []string `json:"items"`
+}
+
+// PageTable 定義了頁面中的表格
+type PageTable struct {
+	Caption string     `json:"caption,omitempty"`
+	Headers []string   `json:"headers,omitempty"`
+	Rows    [][]string `json:"rows"`
+}
+
+// PageLink 定義了頁面中的連結
+type PageLink struct {
+	Text string `json:"text"`
+	Href string `json:"href"`
+}
+
+// languagePattern 匹配 BCP 47 語言標籤
+var languagePattern = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{1,8})*$`)
+
+// UnmarshalJSON 解析頁面內容，除了物件外也接受舊版的字串：
+// 字串內容為 JSON 物件時按版本 1 解析，否則視為純文本正文
+func (p *PageContent) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	switch {
+	case bytes.Equal(data, []byte("null")):
+		return nil
+	case len(data) > 0 && data[0] == '"':
+		var text string
+		if err := json.Unmarshal(data, &text); err != nil {
+			return err
+		}
+		trimmed := strings.TrimSpace(text)
+		if strings.HasPrefix(trimmed, "{") && json.Valid([]byte(trimmed)) {
+			return p.decodeObject([]byte(trimmed))
+		}
+		*p = PageContent{Version: 1, BodyText: trimmed}
+		return nil
+	case len(data) > 0 && data[0] == '{':
+		return p.decodeObject(data)
+	default:
+		return fmt.Errorf("頁面內容必須是物件或字串")
+	}
+}
+
+// decodeObject 解析 JSON 物件，未標示版本的視為版本 1，並清理空白項目
+func (p *PageContent) decodeObject(data []byte) error {
+	// 使用別名類型避免遞迴呼叫 UnmarshalJSON
+	type pageContent PageContent
+	var decoded pageContent
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return fmt.Errorf("解析頁面內容失敗: %w", err)
+	}
+
+	*p = PageContent(decoded)
+	if p.Version == 0 {
+		p.Version = 1
+	}
+	p.normalize()
+	return nil
+}
+
+// UnmarshalJSON 解析標題，也接受版本 1 的純字串
+func (h *PageHeading) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		return json.Unmarshal(data, &h.Text)
+	}
+	type pageHeading PageHeading
+	return json.Unmarshal(data, (*pageHeading)(h))
+}
+
+// normalize 去除項目前後空白，移除空項目以及不可用的連結
+func (p *PageContent) normalize() {
+	p.Language = strings.TrimSpace(p.Language)
+	p.SelectedText = strings.TrimSpace(p.SelectedText)
+	p.BodyText = strings.TrimSpace(p.BodyText)
+	p.Markdown = strings.TrimSpace(p.Markdown)
+	p.Paragraphs = compactStrings(p.Paragraphs)
+	// 頁面保留原來的位置以對應頁碼
+	for i, text := range p.Pages {
+		p.Pages[i] = strings.TrimSpace(text)
+	}
+
+	meta := map[string]string{}
+	for key, value := range p.Meta {
+		if key, value = strings.TrimSpace(key), strings.TrimSpace(value); value != "" {
+			meta[key] = value
+		}
+	}
+	p.Meta = meta
+
+	headings := p.Headings[:0]
+	for _, heading := range p.Headings {
+		if heading.Text = strings.TrimSpace(heading.Text); heading.Text != "" {
+			headings = append(headings, heading)
+		}
+	}
+	p.Headings = headings
+
+	lists := p.Lists[:0]
+	for _, list := range p.Lists {
+		if list.Items = compactStrings(list.Items); len(list.Items) > 0 {
+			lists = append(lists, list)
+		}
+	}
+	p.Lists = lists
+
+	tables := p.Tables[:0]
+	for _, table := range p.Tables {
+		table.Caption = strings.TrimSpace(table.Caption)
+		rows := table.Rows[:0]
+		for _, row := range table.Rows {
+			if len(compactStrings(row)) > 0 {
+				rows = append(rows, row)
+			}
+		}
+		table.Rows = rows
+		if len(table.Rows) > 0 || len(compactStrings(table.Headers)) > 0 {
+			tables = append(tables, table)
+		}
+	}
+	p.Tables = tables
+
+	links := p.Links[:0]
+	for _, link := range p.Links {
+		link.Text, link.Href = strings.TrimSpace(link.Text), strings.TrimSpace(link.Href)
+		if link.Text != "" && isWebLink(link.Href) {
+			links = append(links, link)
+		}
+	}
+	p.Links = links
+}
+
+// Validate 檢查頁面內容的版本和欄位
+func (p *PageContent) Validate() error {
+	if p == nil {
+		return nil
+	}
+	if p.Version < 1 || p.Version > PageContentVersion {
+		return fmt.Errorf("不支援的頁面內容版本: %d（支援 1 到 %d）", p.Version, PageContentVersion)
+	}
+	if p.Language != "" && !languagePattern.MatchString(p.Language) {
+		return fmt.Errorf("無效的頁面語言標籤: %q", p.Language)
+	}
+	for key := range p.Meta {
+		if key == "" {
+			return fmt.Errorf("頁面 meta 標籤缺少名稱")
+		}
+	}
+	for _, heading := range p.Headings {
+		if heading.Level < 0 || heading.Level > 6 {
+			return fmt.Errorf("無效的標題層級 %d: %q", heading.Level, heading.Text)
+		}
+	}
+	return nil
+}
+
+// IsEmpty 判斷是否沒有任何頁面內容
+func (p *PageContent) IsEmpty() bool {
+	return p == nil || p.Size() == 0
+}
+
+// Size 返回頁面內容中所有文字的位元組數
+func (p *PageContent) Size() int {
+	if p == nil {
+		return 0
+	}
+	size := len(p.SelectedText) + len(p.BodyText) + len(p.Markdown)
+	for _, value := range p.Meta {
+		size += len(value)
+	}
+	for _, heading := range p.Headings {
+		size += len(heading.Text)
+	}
+	for _, paragraph := range p.Paragraphs {
+		size += len(paragraph)
+	}
+	for _, text := range p.Pages {
+		size += len(text)
+	}
+	for _, list := range p.Lists {
+		for _, item := range list.Items {
+			size += len(item)
+		}
+	}
+	for _, table := range p.Tables {
+		size += len(table.Caption)
+		for _, header := range table.Headers {
+			size += len(header)
+		}
+		for _, row := range table.Rows {
+			for _, cell := range row {
+				size += len(cell)
+			}
+		}
+	}
+	for _, link := range p.Links {
+		size += len(link.Text) + len(link.Href)
+	}
+	return size
+}
+
+// Hash 返回頁面內容的 SHA-256 雜湊，用於在對話記錄中引用和去重
+func (p *PageContent) Hash() string {
+	data, _ := json.Marshal(p)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// compactStrings 去除每個字串前後空白並移除空字串
+func compactStrings(items []string) []string {
+	list := []string{}
+	for _, item := range items {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// isWebLink 判斷連結是否為 http、https 或 mailto，排除 javascript: 等無意義的連結
+func isWebLink(href string) bool {
+	u, err := url.Parse(href)
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https", "mailto":
+		return true
+	}
+	return false
+}
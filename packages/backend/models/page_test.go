@@ -0,0 +1,139 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestPageContentUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    PageContent
+		wantErr bool
+	}{
+		{
+			name: "legacy plain text",
+			data: `"  正文內容  "`,
+			want: PageContent{Version: 1, BodyText: "正文內容"},
+		},
+		{
+			name: "legacy json string",
+			data: `"{\"headings\":[\"標題\"],\"paragraphs\":[\"段落\",\" \"],\"bodyText\":\"正文\"}"`,
+			want: PageContent{
+				Version:    1,
+				Meta:       map[string]string{},
+				Headings:   []PageHeading{{Text: "標題"}},
+				Paragraphs: []string{"段落"},
+				BodyText:   "正文",
+			},
+		},
+		{
+			name: "version 2 object",
+			data: `{"version":2,"language":"zh-TW","meta":{"author":" 作者 ","empty":""},
+				"headings":[{"level":2,"text":" 小節 "},{"level":3,"text":""}],
+				"lists":[{"ordered":true,"items":["一"," "]},{"items":[""]}],
+				"tables":[{"headers":["欄"],"rows":[["值"],[" "]]}],
+				"links":[{"text":"網站","href":"https://example.com"},{"text":"腳本","href":"javascript:void(0)"}]}`,
+			want: PageContent{
+				Version:  2,
+				Language: "zh-TW",
+				Meta:     map[string]string{"author": "作者"},
+				Headings: []PageHeading{{Level: 2, Text: "小節"}},
+				Lists:    []PageList{{Ordered: true, Items: []string{"一"}}},
+				Tables:   []PageTable{{Headers: []string{"欄"}, Rows: [][]string{{"值"}}}},
+				Links:    []PageLink{{Text: "網站", Href: "https://example.com"}},
+			},
+		},
+		{
+			name: "missing version is version 1",
+			data: `{"bodyText":"正文"}`,
+			want: PageContent{Version: 1, Meta: map[string]string{}, BodyText: "正文"},
+		},
+		{
+			name: "pdf pages keep their positions",
+			data: `{"version":2,"pages":[" 第一頁 ",""," 第三頁"]}`,
+			want: PageContent{Version: 2, Meta: map[string]string{}, Pages: []string{"第一頁", "", "第三頁"}},
+		},
+		{name: "null", data: `null`, want: PageContent{}},
+		{name: "number", data: `42`, wantErr: true},
+		{name: "array", data: `["正文"]`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got PageContent
+			err := json.Unmarshal([]byte(tt.data), &got)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unmarshal error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			// 以編碼結果比較，忽略 nil 與空切片的差別
+			gotJSON, _ := json.Marshal(got)
+			wantJSON, _ := json.Marshal(tt.want)
+			if string(gotJSON) != string(wantJSON) {
+				t.Errorf("Unmarshal = %s, want %s", gotJSON, wantJSON)
+			}
+		})
+	}
+}
+
+func TestPageContentValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		page    *PageContent
+		wantErr bool
+	}{
+		{"nil", nil, false},
+		{"version 1", &PageContent{Version: 1}, false},
+		{"current version", &PageContent{Version: PageContentVersion, Language: "zh-Hant-TW"}, false},
+		{"version 0", &PageContent{}, true},
+		{"future version", &PageContent{Version: PageContentVersion + 1}, true},
+		{"invalid language", &PageContent{Version: 2, Language: "中文"}, true},
+		{"empty meta key", &PageContent{Version: 2, Meta: map[string]string{"": "值"}}, true},
+		{"heading level too deep", &PageContent{Version: 2, Headings: []PageHeading{{Level: 7, Text: "標題"}}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.page.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPageContentIsEmpty(t *testing.T) {
+	tests := []struct {
+		name string
+		page *PageContent
+		want bool
+	}{
+		{"nil", nil, true},
+		{"version only", &PageContent{Version: 2}, true},
+		{"body text", &PageContent{BodyText: "正文"}, false},
+		{"table cell", &PageContent{Tables: []PageTable{{Rows: [][]string{{"值"}}}}}, false},
+		{"pdf page", &PageContent{Pages: []string{"第一頁"}}, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.page.IsEmpty(); got != tt.want {
+			t.Errorf("%s: IsEmpty() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPageContentHash(t *testing.T) {
+	a := PageContent{Version: 2, BodyText: "正文"}
+	b := PageContent{Version: 2, BodyText: "正文"}
+	c := PageContent{Version: 2, BodyText: "其他"}
+
+	if a.Hash() != b.Hash() {
+		t.Error("equal pages have different hashes")
+	}
+	if a.Hash() == c.Hash() {
+		t.Error("different pages have the same hash")
+	}
+}
@@ -0,0 +1,313 @@
+package search
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/rocker15962/llm-web-assistant/packages/backend/models"
+)
+
+// BM25 參數
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// 摘錄在命中位置前後保留的字元數
+const (
+	snippetBefore = 40
+	snippetAfter  = 80
+)
+
+// Document 是索引中的一輪問答
+type Document struct {
+	ID             string
+	ConversationID string
+	AnswerID       string
+	Question       string
+	Answer         string
+	Title          string
+	URL            string
+	CreatedAt      time.Time
+}
+
+// Index 是記憶體中的 BM25 倒排索引，可安全地並發使用
+type Index struct {
+	mu       sync.RWMutex
+	docs     map[string]Document
+	lengths  map[string]int
+	postings map[string]map[string]int // 詞項 -> 文件 ID -> 詞頻
+	totalLen int
+}
+
+// NewIndex 建立空的索引
+func NewIndex() *Index {
+	idx := &Index{}
+	idx.reset()
+	return idx
+}
+
+// reset 清空索引，調用者必須持有寫鎖或索引尚未共享
+func (idx *Index) reset() {
+	idx.docs = map[string]Document{}
+	idx.lengths = map[string]int{}
+	idx.postings = map[string]map[string]int{}
+	idx.totalLen = 0
+}
+
+// DocumentFor 將對話中的一輪問答轉為索引文件
+func DocumentFor(conversationID string, turn models.ConversationTurn) Document {
+	return Document{
+		ID:             turn.ID,
+		ConversationID: conversationID,
+		AnswerID:       turn.ID,
+		Question:       turn.Question,
+		Answer:         turn.Answer,
+		Title:          turn.Title,
+		URL:            turn.URL,
+		CreatedAt:      turn.CreatedAt,
+	}
+}
+
+// AddConversation 加入或更新對話中的所有輪次
+func (idx *Index) AddConversation(conversationID string, turns []models.ConversationTurn) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	for i, turn := range turns {
+		doc := DocumentFor(conversationID, turn)
+		// 加入回答 ID 之前保存的輪次以對話 ID 和位置作為文件 ID
+		if doc.ID == "" {
+			doc.ID = fmt.Sprintf("%s#%d", conversationID, i)
+		}
+		idx.remove(doc.ID)
+		idx.add(doc)
+	}
+}
+
+// Add 加入或更新文件
+func (idx *Index) Add(doc Document) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.remove(doc.ID)
+	idx.add(doc)
+}
+
+// RemoveConversation 移除對話的所有文件
+func (idx *Index) RemoveConversation(conversationID string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	for id, doc := range idx.docs {
+		if doc.ConversationID == conversationID {
+			idx.remove(id)
+		}
+	}
+}
+
+// Clear 移除所有文件
+func (idx *Index) Clear() {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.reset()
+}
+
+// Len 返回索引中的文件數
+func (idx *Index) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	return len(idx.docs)
+}
+
+// add 將文件加入倒排索引，調用者必須持有寫鎖
+func (idx *Index) add(doc Document) {
+	terms := Tokenize(doc.Title + "\n" + doc.Question + "\n" + doc.Answer)
+
+	idx.docs[doc.ID] = doc
+	idx.lengths[doc.ID] = len(terms)
+	idx.totalLen += len(terms)
+
+	for _, term := range terms {
+		postings, ok := idx.postings[term]
+		if !ok {
+			postings = map[string]int{}
+			idx.postings[term] = postings
+		}
+		postings[doc.ID]++
+	}
+}
+
+// remove 從倒排索引移除文件，調用者必須持有寫鎖
+func (idx *Index) remove(id string) {
+	doc, ok := idx.docs[id]
+	if !ok {
+		return
+	}
+
+	for _, term := range Tokenize(doc.Title + "\n" + doc.Question + "\n" + doc.Answer) {
+		if postings, ok := idx.postings[term]; ok {
+			delete(postings, id)
+			if len(postings) == 0 {
+				delete(idx.postings, term)
+			}
+		}
+	}
+
+	idx.totalLen -= idx.lengths[id]
+	delete(idx.lengths, id)
+	delete(idx.docs, id)
+}
+
+// Search 以 BM25 排序返回最相關的文件，limit 為最多返回的數量
+func (idx *Index) Search(query string, limit int) []models.SearchHit {
+	terms := uniqueTerms(QueryTerms(query))
+	if len(terms) == 0 {
+		return []models.SearchHit{}
+	}
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	scores := idx.score(terms)
+
+	ids := make([]string, 0, len(scores))
+	for id := range scores {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if scores[ids[i]] != scores[ids[j]] {
+			return scores[ids[i]] > scores[ids[j]]
+		}
+		return idx.docs[ids[i]].CreatedAt.After(idx.docs[ids[j]].CreatedAt)
+	})
+	if limit > 0 && len(ids) > limit {
+		ids = ids[:limit]
+	}
+
+	hits := []models.SearchHit{}
+	for _, id := range ids {
+		doc := idx.docs[id]
+		hits = append(hits, models.SearchHit{
+			ConversationID: doc.ConversationID,
+			AnswerID:       doc.AnswerID,
+			Question:       doc.Question,
+			Title:          doc.Title,
+			URL:            doc.URL,
+			CreatedAt:      doc.CreatedAt,
+			Score:          math.Round(scores[id]*1000) / 1000,
+			Snippet:        Snippet(doc.Answer, terms, doc.Question),
+		})
+	}
+	return hits
+}
+
+// score 計算包含任一查詢詞項的文件的 BM25 分數，調用者必須持有讀鎖
+func (idx *Index) score(terms []string) map[string]float64 {
+	scores := map[string]float64{}
+	n := float64(len(idx.docs))
+	if n == 0 {
+		return scores
+	}
+	avgLen := float64(idx.totalLen) / n
+
+	for _, term := range terms {
+		postings := idx.postings[term]
+		if len(postings) == 0 {
+			continue
+		}
+		df := float64(len(postings))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		for id, tf := range postings {
+			scores[id] += BM25(float64(tf), float64(idx.lengths[id]), avgLen, idf)
+		}
+	}
+	return scores
+}
+
+// BM25 計算單一詞項對文件的 BM25 分數
+func BM25(tf, docLen, avgLen, idf float64) float64 {
+	if avgLen == 0 {
+		avgLen = 1
+	}
+	return idf * tf * (bm25K1 + 1) / (tf + bm25K1*(1-bm25B+bm25B*docLen/avgLen))
+}
+
+// uniqueTerms 去除重複的詞項並保持順序
+func uniqueTerms(terms []string) []string {
+	seen := map[string]bool{}
+	unique := []string{}
+	for _, term := range terms {
+		if !seen[term] {
+			seen[term] = true
+			unique = append(unique, term)
+		}
+	}
+	return unique
+}
+
+// Snippet 返回文本中第一個命中詞項附近的摘錄，text 沒有命中時改用 fallback
+func Snippet(text string, terms []string, fallback string) string {
+	if snippet, ok := snippetAround(text, terms); ok {
+		return snippet
+	}
+	if snippet, ok := snippetAround(fallback, terms); ok {
+		return snippet
+	}
+	return truncateRunes(collapseSpace(text), snippetBefore+snippetAfter)
+}
+
+// snippetAround 在文本中尋找最早的命中位置並截取前後內容
+func snippetAround(text string, terms []string) (string, bool) {
+	runes := []rune(collapseSpace(text))
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+	haystack := string(lower)
+
+	best := -1
+	for _, term := range terms {
+		if pos := strings.Index(haystack, term); pos >= 0 {
+			// 轉換為字元位置
+			runePos := len([]rune(haystack[:pos]))
+			if best < 0 || runePos < best {
+				best = runePos
+			}
+		}
+	}
+	if best < 0 {
+		return "", false
+	}
+
+	start, end := best-snippetBefore, best+snippetAfter
+	prefix, suffix := "…", "…"
+	if start <= 0 {
+		start, prefix = 0, ""
+	}
+	if end >= len(runes) {
+		end, suffix = len(runes), ""
+	}
+	return prefix + string(runes[start:end]) + suffix, true
+}
+
+// collapseSpace 將連續的空白（包括換行）合併為單一空格
+func collapseSpace(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// truncateRunes 將文本截斷為最多 n 個字元
+func truncateRunes(text string, n int) string {
+	runes := []rune(text)
+	if len(runes) <= n {
+		return text
+	}
+	return string(runes[:n]) + "…"
+}
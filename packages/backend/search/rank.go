@@ -0,0 +1,46 @@
+package search
+
+import "math"
+
+// RankPassages 以 BM25 計算每個段落與查詢的相關度，返回與段落順序對應的分數
+// 段落集合本身作為語料計算 IDF，適用於對單一頁面的段落排序
+func RankPassages(query string, passages []string) []float64 {
+	scores := make([]float64, len(passages))
+	terms := uniqueTerms(QueryTerms(query))
+	if len(terms) == 0 || len(passages) == 0 {
+		return scores
+	}
+
+	frequencies := make([]map[string]int, len(passages))
+	lengths := make([]float64, len(passages))
+	documentFrequency := map[string]int{}
+	totalLen := 0.0
+	for i, passage := range passages {
+		tokens := Tokenize(passage)
+		frequencies[i] = map[string]int{}
+		for _, token := range tokens {
+			frequencies[i][token]++
+		}
+		for term := range frequencies[i] {
+			documentFrequency[term]++
+		}
+		lengths[i] = float64(len(tokens))
+		totalLen += lengths[i]
+	}
+
+	n := float64(len(passages))
+	avgLen := totalLen / n
+	for _, term := range terms {
+		df := float64(documentFrequency[term])
+		if df == 0 {
+			continue
+		}
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		for i := range passages {
+			if tf := frequencies[i][term]; tf > 0 {
+				scores[i] += BM25(float64(tf), lengths[i], avgLen, idf)
+			}
+		}
+	}
+	return scores
+}
@@ -0,0 +1,51 @@
+package search
+
+import (
+	"testing"
+)
+
+func TestRankPassages(t *testing.T) {
+	passages := []string{
+		"今天天氣晴朗，適合出門散步。",
+		"量子電腦利用量子位元進行運算，量子糾纏是其中的關鍵。",
+		"本站使用 cookies 改善瀏覽體驗。",
+		"量子力學是描述微觀世界的理論。",
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		best  int // 分數最高的段落，-1 表示所有分數都是 0
+	}{
+		{"cjk query", "量子糾纏是什麼", 1},
+		{"latin query", "Cookies", 2},
+		{"weather", "天氣如何", 0},
+		{"no overlap", "火星探測", -1},
+		{"empty query", "", -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scores := RankPassages(tt.query, passages)
+			if len(scores) != len(passages) {
+				t.Fatalf("len(scores) = %d, want %d", len(scores), len(passages))
+			}
+
+			best, bestScore := -1, 0.0
+			for i, score := range scores {
+				if score > bestScore {
+					best, bestScore = i, score
+				}
+			}
+			if best != tt.best {
+				t.Errorf("RankPassages(%q) best = %d, want %d (scores %v)", tt.query, best, tt.best, scores)
+			}
+		})
+	}
+}
+
+func TestRankPassagesEmpty(t *testing.T) {
+	if scores := RankPassages("量子", nil); len(scores) != 0 {
+		t.Errorf("RankPassages with no passages = %v", scores)
+	}
+}
@@ -0,0 +1,70 @@
+package search
+
+import (
+	"unicode"
+)
+
+// Tokenize 將文本切分為索引用的詞項
+// 拉丁字母和數字以單詞為單位並轉為小寫；CJK 文字沒有空格分詞，
+// 因此同時產生單字和相鄰兩字的 bigram，讓單字與多字的查詢都能命中
+func Tokenize(text string) []string {
+	return tokenize(text, true)
+}
+
+// QueryTerms 將查詢切分為詞項
+// 連續的 CJK 文字只使用 bigram，以避免單字匹配造成的大量雜訊
+func QueryTerms(text string) []string {
+	return tokenize(text, false)
+}
+
+// tokenize 切分文本，withUnigrams 控制連續 CJK 文字是否同時產生單字詞項
+func tokenize(text string, withUnigrams bool) []string {
+	terms := []string{}
+	var word, cjk []rune
+
+	flushWord := func() {
+		if len(word) > 0 {
+			terms = append(terms, string(word))
+			word = word[:0]
+		}
+	}
+	flushCJK := func() {
+		switch {
+		case len(cjk) == 1:
+			terms = append(terms, string(cjk))
+		case len(cjk) > 1:
+			for i := 0; i < len(cjk); i++ {
+				if withUnigrams {
+					terms = append(terms, string(cjk[i]))
+				}
+				if i+1 < len(cjk) {
+					terms = append(terms, string(cjk[i:i+2]))
+				}
+			}
+		}
+		cjk = cjk[:0]
+	}
+
+	for _, r := range text {
+		switch {
+		case IsCJK(r):
+			flushWord()
+			cjk = append(cjk, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushCJK()
+			word = append(word, unicode.ToLower(r))
+		default:
+			flushWord()
+			flushCJK()
+		}
+	}
+	flushWord()
+	flushCJK()
+
+	return terms
+}
+
+// IsCJK 判斷字元是否為中日韓文字，搜索分詞和 token 估計共用
+func IsCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
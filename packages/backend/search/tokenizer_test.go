@@ -0,0 +1,71 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"latin words are lowercased", "Hello, World 2024!", []string{"hello", "world", "2024"}},
+		{"cjk unigrams and bigrams", "機器學習", []string{"機", "機器", "器", "器學", "學", "學習", "習"}},
+		{"single cjk character", "書", []string{"書"}},
+		{"mixed scripts split at boundaries", "GPT模型", []string{"gpt", "模", "模型", "型"}},
+		{"punctuation separates cjk runs", "你好，世界", []string{"你", "你好", "好", "世", "世界", "界"}},
+		{"kana and hangul are cjk", "カナ한글", []string{"カ", "カナ", "ナ", "ナ한", "한", "한글", "글"}},
+		{"empty", "", []string{}},
+		{"only punctuation", "。、!?", []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Tokenize(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Tokenize(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryTerms(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		// 連續的 CJK 文字只使用 bigram
+		{"機器學習", []string{"機器", "器學", "學習"}},
+		// 單一 CJK 字元仍然保留
+		{"書 API", []string{"書", "api"}},
+		{"Go 語言", []string{"go", "語言"}},
+	}
+
+	for _, tt := range tests {
+		if got := QueryTerms(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("QueryTerms(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestIsCJK(t *testing.T) {
+	tests := []struct {
+		r    rune
+		want bool
+	}{
+		{'中', true},
+		{'あ', true},
+		{'カ', true},
+		{'한', true},
+		{'a', false},
+		{'1', false},
+		{'，', false},
+	}
+
+	for _, tt := range tests {
+		if got := IsCJK(tt.r); got != tt.want {
+			t.Errorf("IsCJK(%q) = %v, want %v", tt.r, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,317 @@
+package store
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/rocker15962/llm-web-assistant/packages/backend/models"
+)
+
+// BoltDB 的佈局：conversations bucket 中每個對話 ID 對應一個子 bucket，
+// 子 bucket 的 summary 鍵保存不含輪次的對話摘要，turns 子 bucket 以遞增序號保存每一輪，
+// pages 和 screenshots 子 bucket 以雜湊保存請求的頁面內容和截圖；
+// 加入一輪只需寫入新的鍵並更新摘要，列出對話時也不必解析輪次
+var (
+	conversationsBucket = []byte("conversations")
+	summaryKey          = []byte("summary")
+	turnsBucket         = []byte("turns")
+	pagesBucket         = []byte("pages")
+	screenshotsBucket   = []byte("screenshots")
+)
+
+// BoltStore 使用嵌入式 BoltDB 持久化對話記錄
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// OpenBoltStore 開啟（或建立）BoltDB 資料庫檔案
+func OpenBoltStore(path string) (*BoltStore, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("建立資料目錄失敗: %w", err)
+		}
+	}
+
+	// 設置超時，避免檔案被其他進程鎖定時無限等待
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("開啟 BoltDB 失敗: %w", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(conversationsBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("初始化 BoltDB 失敗: %w", err)
+	}
+
+	return &BoltStore{db: db}, nil
+}
+
+// putTurn 在對話的子 bucket 中加入一輪問答並更新摘要，對話不存在時會自動建立
+func putTurn(bucket *bolt.Bucket, conversationID string, turn models.ConversationTurn) error {
+	convBucket, err := bucket.CreateBucketIfNotExists([]byte(conversationID))
+	if err != nil {
+		return err
+	}
+	turns, err := convBucket.CreateBucketIfNotExists(turnsBucket)
+	if err != nil {
+		return err
+	}
+
+	var conv models.Conversation
+	if data := convBucket.Get(summaryKey); data != nil {
+		if err := json.Unmarshal(data, &conv); err != nil {
+			return err
+		}
+	}
+	countTurn(&conv, conversationID, turn)
+
+	seq, err := turns.NextSequence()
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(turn)
+	if err != nil {
+		return err
+	}
+	if err := turns.Put(turnKey(seq), data); err != nil {
+		return err
+	}
+
+	data, err = json.Marshal(conv)
+	if err != nil {
+		return err
+	}
+	return convBucket.Put(summaryKey, data)
+}
+
+// turnKey 將序號編碼為大端序的鍵，使遍歷順序與加入順序一致
+func turnKey(seq uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, seq)
+	return key
+}
+
+// readSummary 讀取對話的摘要
+func readSummary(convBucket *bolt.Bucket) (models.Conversation, error) {
+	var conv models.Conversation
+	data := convBucket.Get(summaryKey)
+	if data == nil {
+		return conv, nil
+	}
+	err := json.Unmarshal(data, &conv)
+	return conv, err
+}
+
+// readTurns 按加入順序讀取對話的所有輪次
+func readTurns(convBucket *bolt.Bucket) ([]models.ConversationTurn, error) {
+	turns := []models.ConversationTurn{}
+	bucket := convBucket.Bucket(turnsBucket)
+	if bucket == nil {
+		return turns, nil
+	}
+	err := bucket.ForEach(func(_, data []byte) error {
+		var turn models.ConversationTurn
+		if err := json.Unmarshal(data, &turn); err != nil {
+			return err
+		}
+		turns = append(turns, turn)
+		return nil
+	})
+	return turns, err
+}
+
+// Turns 返回對話的所有輪次
+func (s *BoltStore) Turns(conversationID string) ([]models.ConversationTurn, bool, error) {
+	var (
+		turns []models.ConversationTurn
+		found bool
+	)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		convBucket := tx.Bucket(conversationsBucket).Bucket([]byte(conversationID))
+		if convBucket == nil {
+			return nil
+		}
+		found = true
+		var err error
+		turns, err = readTurns(convBucket)
+		return err
+	})
+	return turns, found, err
+}
+
+// AppendTurn 在對話末尾加入一輪問答
+func (s *BoltStore) AppendTurn(conversationID string, turn models.ConversationTurn) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return putTurn(tx.Bucket(conversationsBucket), conversationID, turn)
+	})
+}
+
+// ListConversations 返回所有對話的摘要，只讀取摘要記錄
+func (s *BoltStore) ListConversations() ([]models.Conversation, error) {
+	conversations := []models.Conversation{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(conversationsBucket)
+		return bucket.ForEach(func(key, _ []byte) error {
+			convBucket := bucket.Bucket(key)
+			if convBucket == nil {
+				return nil
+			}
+			conv, err := readSummary(convBucket)
+			if err != nil {
+				return err
+			}
+			conversations = append(conversations, conv)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sortByUpdated(conversations)
+	return conversations, nil
+}
+
+// GetConversation 返回包含所有輪次的對話
+func (s *BoltStore) GetConversation(conversationID string) (models.Conversation, bool, error) {
+	var (
+		conv  models.Conversation
+		found bool
+	)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		convBucket := tx.Bucket(conversationsBucket).Bucket([]byte(conversationID))
+		if convBucket == nil {
+			return nil
+		}
+		found = true
+
+		var err error
+		if conv, err = readSummary(convBucket); err != nil {
+			return err
+		}
+		conv.Turns, err = readTurns(convBucket)
+		return err
+	})
+	return conv, found, err
+}
+
+// PutPage 保存對話中請求的頁面內容
+func (s *BoltStore) PutPage(conversationID, hash string, page models.PageContent) error {
+	data, err := json.Marshal(page)
+	if err != nil {
+		return err
+	}
+	return s.putByHash(pagesBucket, conversationID, hash, data)
+}
+
+// Page 以雜湊讀取對話中保存的頁面內容
+func (s *BoltStore) Page(conversationID, hash string) (models.PageContent, bool, error) {
+	var page models.PageContent
+	data, err := s.getByHash(pagesBucket, conversationID, hash)
+	if err != nil || data == nil {
+		return page, false, err
+	}
+	return page, true, json.Unmarshal(data, &page)
+}
+
+// PutScreenshot 保存對話中請求的截圖
+func (s *BoltStore) PutScreenshot(conversationID, hash, screenshot string) error {
+	return s.putByHash(screenshotsBucket, conversationID, hash, []byte(screenshot))
+}
+
+// Screenshot 以雜湊讀取對話中保存的截圖
+func (s *BoltStore) Screenshot(conversationID, hash string) (string, bool, error) {
+	data, err := s.getByHash(screenshotsBucket, conversationID, hash)
+	if err != nil || data == nil {
+		return "", false, err
+	}
+	return string(data), true, nil
+}
+
+// putByHash 在對話的子 bucket 中以雜湊保存數據，相同雜湊只保存一次
+func (s *BoltStore) putByHash(name []byte, conversationID, hash string, data []byte) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		convBucket := tx.Bucket(conversationsBucket).Bucket([]byte(conversationID))
+		if convBucket == nil {
+			return fmt.Errorf("找不到對話: %s", conversationID)
+		}
+		bucket, err := convBucket.CreateBucketIfNotExists(name)
+		if err != nil {
+			return err
+		}
+		if bucket.Get([]byte(hash)) != nil {
+			return nil
+		}
+		return bucket.Put([]byte(hash), data)
+	})
+}
+
+// getByHash 從對話的子 bucket 中以雜湊讀取數據，不存在時返回 nil
+func (s *BoltStore) getByHash(name []byte, conversationID, hash string) ([]byte, error) {
+	var data []byte
+	err := s.db.View(func(tx *bolt.Tx) error {
+		convBucket := tx.Bucket(conversationsBucket).Bucket([]byte(conversationID))
+		if convBucket == nil {
+			return nil
+		}
+		bucket := convBucket.Bucket(name)
+		if bucket == nil {
+			return nil
+		}
+		// 值只在交易內有效，需要複製
+		if value := bucket.Get([]byte(hash)); value != nil {
+			data = append([]byte(nil), value...)
+		}
+		return nil
+	})
+	return data, err
+}
+
+// DeleteConversation 刪除對話
+func (s *BoltStore) DeleteConversation(conversationID string) (bool, error) {
+	var found bool
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(conversationsBucket)
+		if bucket.Bucket([]byte(conversationID)) == nil {
+			return nil
+		}
+		found = true
+		return bucket.DeleteBucket([]byte(conversationID))
+	})
+	return found, err
+}
+
+// DeleteAllConversations 刪除所有對話
+func (s *BoltStore) DeleteAllConversations() (int, error) {
+	var count int
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		err := tx.Bucket(conversationsBucket).ForEach(func(_, _ []byte) error {
+			count++
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if err := tx.DeleteBucket(conversationsBucket); err != nil {
+			return err
+		}
+		_, err = tx.CreateBucket(conversationsBucket)
+		return err
+	})
+	return count, err
+}
+
+// Close 關閉資料庫
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,158 @@
+package store
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rocker15962/llm-web-assistant/packages/backend/models"
+)
+
+func openTestStore(t *testing.T, path string) *BoltStore {
+	t.Helper()
+	s, err := OpenBoltStore(path)
+	if err != nil {
+		t.Fatalf("OpenBoltStore: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func testTurn(i int) models.ConversationTurn {
+	return models.ConversationTurn{
+		Question:  fmt.Sprintf("q%d", i),
+		Answer:    fmt.Sprintf("a%d", i),
+		URL:       "https://example.com",
+		Title:     "Example",
+		Usage:     models.TokenUsage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15},
+		CreatedAt: time.Unix(int64(1000+i), 0).UTC(),
+	}
+}
+
+func TestBoltStoreAppendAndList(t *testing.T) {
+	s := openTestStore(t, filepath.Join(t.TempDir(), "conv.db"))
+
+	// 超過 255 輪以確認序號鍵的順序
+	for i := 0; i < 300; i++ {
+		if err := s.AppendTurn("conv-a", testTurn(i)); err != nil {
+			t.Fatalf("AppendTurn: %v", err)
+		}
+	}
+	if err := s.AppendTurn("conv-b", testTurn(500)); err != nil {
+		t.Fatalf("AppendTurn: %v", err)
+	}
+
+	turns, found, err := s.Turns("conv-a")
+	if err != nil || !found {
+		t.Fatalf("Turns = %v, %v", found, err)
+	}
+	if len(turns) != 300 {
+		t.Fatalf("len(turns) = %d, want 300", len(turns))
+	}
+	for i, turn := range turns {
+		if turn.Question != fmt.Sprintf("q%d", i) {
+			t.Fatalf("turns[%d].Question = %q", i, turn.Question)
+		}
+	}
+
+	list, err := s.ListConversations()
+	if err != nil {
+		t.Fatalf("ListConversations: %v", err)
+	}
+	if len(list) != 2 || list[0].ID != "conv-b" || list[1].ID != "conv-a" {
+		t.Fatalf("ListConversations = %+v", list)
+	}
+	a := list[1]
+	if a.TurnCount != 300 || a.Usage.TotalTokens != 300*15 || len(a.Turns) != 0 {
+		t.Errorf("summary = %+v", a)
+	}
+	if !a.CreatedAt.Equal(time.Unix(1000, 0)) || !a.UpdatedAt.Equal(time.Unix(1299, 0)) {
+		t.Errorf("summary times = %v, %v", a.CreatedAt, a.UpdatedAt)
+	}
+
+	if _, found, _ := s.Turns("missing"); found {
+		t.Error("Turns(missing) found")
+	}
+}
+
+func TestBoltStoreDelete(t *testing.T) {
+	s := openTestStore(t, filepath.Join(t.TempDir(), "conv.db"))
+	for _, id := range []string{"a", "b", "c"} {
+		if err := s.AppendTurn(id, testTurn(1)); err != nil {
+			t.Fatalf("AppendTurn: %v", err)
+		}
+	}
+
+	if found, err := s.DeleteConversation("a"); err != nil || !found {
+		t.Fatalf("DeleteConversation(a) = %v, %v", found, err)
+	}
+	if found, err := s.DeleteConversation("a"); err != nil || found {
+		t.Fatalf("DeleteConversation(a) again = %v, %v", found, err)
+	}
+	if count, err := s.DeleteAllConversations(); err != nil || count != 2 {
+		t.Fatalf("DeleteAllConversations = %d, %v", count, err)
+	}
+	if list, _ := s.ListConversations(); len(list) != 0 {
+		t.Errorf("ListConversations after delete = %+v", list)
+	}
+}
+
+func TestBoltStorePages(t *testing.T) {
+	s := openTestStore(t, filepath.Join(t.TempDir(), "conv.db"))
+	page := models.PageContent{Version: models.PageContentVersion, BodyText: "內容"}
+
+	if err := s.PutPage("conv", page.Hash(), page); err == nil {
+		t.Error("PutPage before the conversation exists succeeded")
+	}
+	if err := s.AppendTurn("conv", testTurn(1)); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := s.PutPage("conv", page.Hash(), page); err != nil {
+			t.Fatalf("PutPage: %v", err)
+		}
+	}
+
+	got, found, err := s.Page("conv", page.Hash())
+	if err != nil || !found || got.BodyText != "內容" {
+		t.Fatalf("Page = %+v, %v, %v", got, found, err)
+	}
+	if _, found, _ := s.Page("conv", "missing"); found {
+		t.Error("Page(missing) found")
+	}
+
+	if _, err := s.DeleteConversation("conv"); err != nil {
+		t.Fatal(err)
+	}
+	if _, found, _ := s.Page("conv", page.Hash()); found {
+		t.Error("page survived DeleteConversation")
+	}
+}
+
+func TestBoltStoreScreenshots(t *testing.T) {
+	s := openTestStore(t, filepath.Join(t.TempDir(), "conv.db"))
+	if err := s.AppendTurn("conv", testTurn(1)); err != nil {
+		t.Fatal(err)
+	}
+
+	const screenshot = "data:image/png;base64,iVBORw0KGgo="
+	if err := s.PutScreenshot("conv", "hash", screenshot); err != nil {
+		t.Fatalf("PutScreenshot: %v", err)
+	}
+	got, found, err := s.Screenshot("conv", "hash")
+	if err != nil || !found || got != screenshot {
+		t.Fatalf("Screenshot = %q, %v, %v", got, found, err)
+	}
+	// 頁面和截圖分開保存，相同的雜湊不會互相覆蓋
+	if _, found, _ := s.Page("conv", "hash"); found {
+		t.Error("screenshot hash found as a page")
+	}
+
+	if _, err := s.DeleteConversation("conv"); err != nil {
+		t.Fatal(err)
+	}
+	if _, found, _ := s.Screenshot("conv", "hash"); found {
+		t.Error("screenshot survived DeleteConversation")
+	}
+}
@@ -0,0 +1,67 @@
+package store
+
+import "github.com/rocker15962/llm-web-assistant/packages/backend/models"
+
+// FindTurn 以回答 ID 查找輪次
+func FindTurn(turns []models.ConversationTurn, id string) (models.ConversationTurn, bool) {
+	if id == "" {
+		return models.ConversationTurn{}, false
+	}
+	for _, turn := range turns {
+		if turn.ID == id {
+			return turn, true
+		}
+	}
+	return models.ConversationTurn{}, false
+}
+
+// Branch 返回從第一輪到指定回答（包含）的分支路徑，leafID 為空時只返回舊記錄
+// 加入回答 ID 之前保存的輪次沒有 ID，視為所有分支共同的開頭
+func Branch(turns []models.ConversationTurn, leafID string) []models.ConversationTurn {
+	var chain []models.ConversationTurn
+	seen := map[string]bool{}
+	for id := leafID; id != "" && !seen[id]; {
+		turn, ok := FindTurn(turns, id)
+		if !ok {
+			break
+		}
+		seen[id] = true
+		chain = append(chain, turn)
+		id = turn.ParentID
+	}
+
+	branch := []models.ConversationTurn{}
+	for _, turn := range turns {
+		if turn.ID == "" {
+			branch = append(branch, turn)
+		}
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		branch = append(branch, chain[i])
+	}
+	return branch
+}
+
+// Variants 返回與指定回答位於同一位置（相同 ParentID）的所有回答，按建立順序排列
+func Variants(turns []models.ConversationTurn, id string) []models.ConversationTurn {
+	target, ok := FindTurn(turns, id)
+	if !ok {
+		return nil
+	}
+
+	variants := []models.ConversationTurn{}
+	for _, turn := range turns {
+		if turn.ID != "" && turn.ParentID == target.ParentID {
+			variants = append(variants, turn)
+		}
+	}
+	return variants
+}
+
+// LatestTurnID 返回最新保存的回答 ID
+func LatestTurnID(turns []models.ConversationTurn) string {
+	if len(turns) == 0 {
+		return ""
+	}
+	return turns[len(turns)-1].ID
+}
@@ -0,0 +1,135 @@
+package store
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/rocker15962/llm-web-assistant/packages/backend/models"
+)
+
+// branchTurns 建立以下的對話樹（括號內為 ParentID），legacy 為沒有 ID 的舊記錄：
+//
+//	legacy
+//	a1() ── a2(a1) ── a3(a2)
+//	     └─ b2(a1) ── b3(b2)
+//	c1()
+func branchTurns() []models.ConversationTurn {
+	return []models.ConversationTurn{
+		{Question: "legacy"},
+		{ID: "a1"},
+		{ID: "a2", ParentID: "a1"},
+		{ID: "b2", ParentID: "a1", SourceID: "a2"},
+		{ID: "a3", ParentID: "a2"},
+		{ID: "c1", SourceID: "a1"},
+		{ID: "b3", ParentID: "b2"},
+	}
+}
+
+// turnIDs 返回輪次的 ID，舊記錄以 Question 表示
+func turnIDs(turns []models.ConversationTurn) []string {
+	ids := []string{}
+	for _, turn := range turns {
+		if turn.ID == "" {
+			ids = append(ids, turn.Question)
+			continue
+		}
+		ids = append(ids, turn.ID)
+	}
+	return ids
+}
+
+func TestBranch(t *testing.T) {
+	tests := []struct {
+		name   string
+		turns  []models.ConversationTurn
+		leafID string
+		want   []string
+	}{
+		{"main branch", branchTurns(), "a3", []string{"legacy", "a1", "a2", "a3"}},
+		{"edited branch", branchTurns(), "b3", []string{"legacy", "a1", "b2", "b3"}},
+		{"middle of branch", branchTurns(), "b2", []string{"legacy", "a1", "b2"}},
+		{"regenerated first turn", branchTurns(), "c1", []string{"legacy", "c1"}},
+		{"empty leaf keeps legacy turns", branchTurns(), "", []string{"legacy"}},
+		{"unknown leaf", branchTurns(), "missing", []string{"legacy"}},
+		{"no turns", nil, "a1", []string{}},
+		{
+			"parent cycle stops",
+			[]models.ConversationTurn{{ID: "x", ParentID: "y"}, {ID: "y", ParentID: "x"}},
+			"x",
+			[]string{"y", "x"},
+		},
+		{
+			"missing parent keeps the known suffix",
+			[]models.ConversationTurn{{ID: "x", ParentID: "gone"}},
+			"x",
+			[]string{"x"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := turnIDs(Branch(tt.turns, tt.leafID)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Branch(%q) = %v, want %v", tt.leafID, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVariants(t *testing.T) {
+	tests := []struct {
+		id   string
+		want []string
+	}{
+		{"a2", []string{"a2", "b2"}},
+		{"b2", []string{"a2", "b2"}},
+		{"a1", []string{"a1", "c1"}},
+		{"a3", []string{"a3"}},
+		{"missing", []string{}},
+		{"", []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			if got := turnIDs(Variants(branchTurns(), tt.id)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Variants(%q) = %v, want %v", tt.id, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindTurn(t *testing.T) {
+	tests := []struct {
+		id     string
+		wantOK bool
+	}{
+		{"b2", true},
+		{"missing", false},
+		// 舊記錄沒有 ID，不能以空字串查找
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		turn, ok := FindTurn(branchTurns(), tt.id)
+		if ok != tt.wantOK || (ok && turn.ID != tt.id) {
+			t.Errorf("FindTurn(%q) = %q, %v; want ok %v", tt.id, turn.ID, ok, tt.wantOK)
+		}
+	}
+}
+
+func TestLatestTurnID(t *testing.T) {
+	tests := []struct {
+		name  string
+		turns []models.ConversationTurn
+		want  string
+	}{
+		{"latest appended", branchTurns(), "b3"},
+		{"legacy only", []models.ConversationTurn{{Question: "legacy"}}, ""},
+		{"empty", nil, ""},
+	}
+
+	for _, tt := range tests {
+		if got := LatestTurnID(tt.turns); got != tt.want {
+			t.Errorf("%s: LatestTurnID = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package store
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/rocker15962/llm-web-assistant/packages/backend/models"
+)
+
+// MemoryStore 是保存在記憶體中的對話記錄，進程結束後會遺失
+type MemoryStore struct {
+	mu            sync.RWMutex
+	conversations map[string]models.Conversation
+	pages         map[string]map[string]models.PageContent // 對話 ID -> 雜湊 -> 頁面內容
+	screenshots   map[string]map[string]string             // 對話 ID -> 雜湊 -> 截圖
+}
+
+// NewMemoryStore 建立記憶體對話記錄
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		conversations: map[string]models.Conversation{},
+		pages:         map[string]map[string]models.PageContent{},
+		screenshots:   map[string]map[string]string{},
+	}
+}
+
+// Turns 返回對話的所有輪次
+func (s *MemoryStore) Turns(conversationID string) ([]models.ConversationTurn, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	conv, ok := s.conversations[conversationID]
+	if !ok {
+		return nil, false, nil
+	}
+	// 返回副本，避免呼叫者修改內部數據
+	return append([]models.ConversationTurn(nil), conv.Turns...), true, nil
+}
+
+// AppendTurn 在對話末尾加入一輪問答
+func (s *MemoryStore) AppendTurn(conversationID string, turn models.ConversationTurn) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	conv := s.conversations[conversationID]
+	appendTurn(&conv, conversationID, turn)
+	s.conversations[conversationID] = conv
+	return nil
+}
+
+// ListConversations 返回所有對話的摘要
+func (s *MemoryStore) ListConversations() ([]models.Conversation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	conversations := []models.Conversation{}
+	for _, conv := range s.conversations {
+		conversations = append(conversations, summarize(conv))
+	}
+	sortByUpdated(conversations)
+	return conversations, nil
+}
+
+// GetConversation 返回包含所有輪次的對話
+func (s *MemoryStore) GetConversation(conversationID string) (models.Conversation, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	conv, ok := s.conversations[conversationID]
+	if !ok {
+		return models.Conversation{}, false, nil
+	}
+	conv.Turns = append([]models.ConversationTurn(nil), conv.Turns...)
+	return conv, true, nil
+}
+
+// PutPage 保存對話中請求的頁面內容
+func (s *MemoryStore) PutPage(conversationID, hash string, page models.PageContent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.conversations[conversationID]; !ok {
+		return fmt.Errorf("找不到對話: %s", conversationID)
+	}
+	if s.pages[conversationID] == nil {
+		s.pages[conversationID] = map[string]models.PageContent{}
+	}
+	s.pages[conversationID][hash] = page
+	return nil
+}
+
+// Page 以雜湊讀取對話中保存的頁面內容
+func (s *MemoryStore) Page(conversationID, hash string) (models.PageContent, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	page, ok := s.pages[conversationID][hash]
+	return page, ok, nil
+}
+
+// PutScreenshot 保存對話中請求的截圖
+func (s *MemoryStore) PutScreenshot(conversationID, hash, screenshot string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.conversations[conversationID]; !ok {
+		return fmt.Errorf("找不到對話: %s", conversationID)
+	}
+	if s.screenshots[conversationID] == nil {
+		s.screenshots[conversationID] = map[string]string{}
+	}
+	s.screenshots[conversationID][hash] = screenshot
+	return nil
+}
+
+// Screenshot 以雜湊讀取對話中保存的截圖
+func (s *MemoryStore) Screenshot(conversationID, hash string) (string, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	screenshot, ok := s.screenshots[conversationID][hash]
+	return screenshot, ok, nil
+}
+
+// DeleteConversation 刪除對話
+func (s *MemoryStore) DeleteConversation(conversationID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.conversations[conversationID]; !ok {
+		return false, nil
+	}
+	delete(s.conversations, conversationID)
+	delete(s.pages, conversationID)
+	delete(s.screenshots, conversationID)
+	return true, nil
+}
+
+// DeleteAllConversations 刪除所有對話
+func (s *MemoryStore) DeleteAllConversations() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count := len(s.conversations)
+	s.conversations = map[string]models.Conversation{}
+	s.pages = map[string]map[string]models.PageContent{}
+	s.screenshots = map[string]map[string]string{}
+	return count, nil
+}
+
+// Close 記憶體儲存不需要釋放資源
+func (s *MemoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,88 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/rocker15962/llm-web-assistant/packages/backend/config"
+	"github.com/rocker15962/llm-web-assistant/packages/backend/models"
+)
+
+// 儲存後端名稱
+const (
+	BackendBolt   = "bolt"
+	BackendMemory = "memory"
+)
+
+// ConversationStore 定義了對話記錄的儲存介面，新的後端只需實現此介面
+type ConversationStore interface {
+	// Turns 返回對話的所有輪次，對話不存在時返回 false
+	Turns(conversationID string) ([]models.ConversationTurn, bool, error)
+	// AppendTurn 在對話末尾加入一輪問答，對話不存在時會自動建立
+	AppendTurn(conversationID string, turn models.ConversationTurn) error
+	// ListConversations 返回所有對話的摘要（不含輪次），按更新時間由新到舊排序
+	ListConversations() ([]models.Conversation, error)
+	// GetConversation 返回包含所有輪次的對話
+	GetConversation(conversationID string) (models.Conversation, bool, error)
+	// PutPage 保存對話中請求的頁面內容，相同雜湊只保存一次；對話必須已存在
+	PutPage(conversationID, hash string, page models.PageContent) error
+	// Page 以雜湊讀取對話中保存的頁面內容，不存在時返回 false
+	Page(conversationID, hash string) (models.PageContent, bool, error)
+	// PutScreenshot 保存對話中請求的截圖（data URL），相同雜湊只保存一次；對話必須已存在
+	PutScreenshot(conversationID, hash, screenshot string) error
+	// Screenshot 以雜湊讀取對話中保存的截圖，不存在時返回 false
+	Screenshot(conversationID, hash string) (string, bool, error)
+	// DeleteConversation 刪除對話，對話不存在時返回 false
+	DeleteConversation(conversationID string) (bool, error)
+	// DeleteAllConversations 刪除所有對話並返回刪除數量
+	DeleteAllConversations() (int, error)
+	// Close 釋放儲存資源
+	Close() error
+}
+
+// Open 根據配置開啟對話記錄儲存
+func Open(cfg config.Config) (ConversationStore, error) {
+	switch cfg.StoreBackend {
+	case BackendBolt, "":
+		return OpenBoltStore(cfg.StorePath)
+	case BackendMemory:
+		return NewMemoryStore(), nil
+	default:
+		return nil, fmt.Errorf("不支援的儲存後端: %s", cfg.StoreBackend)
+	}
+}
+
+// appendTurn 將一輪問答加入對話並更新摘要欄位
+func appendTurn(conv *models.Conversation, conversationID string, turn models.ConversationTurn) {
+	conv.Turns = append(conv.Turns, turn)
+	countTurn(conv, conversationID, turn)
+}
+
+// countTurn 以新加入的一輪問答更新對話的摘要欄位，不修改輪次列表
+func countTurn(conv *models.Conversation, conversationID string, turn models.ConversationTurn) {
+	if conv.ID == "" {
+		conv.ID = conversationID
+		conv.URL = turn.URL
+		conv.Title = turn.Title
+		conv.CreatedAt = turn.CreatedAt
+	}
+
+	conv.TurnCount++
+	conv.UpdatedAt = turn.CreatedAt
+	conv.Usage.PromptTokens += turn.Usage.PromptTokens
+	conv.Usage.CompletionTokens += turn.Usage.CompletionTokens
+	conv.Usage.TotalTokens += turn.Usage.TotalTokens
+}
+
+// summarize 返回不含輪次的對話摘要
+func summarize(conv models.Conversation) models.Conversation {
+	conv.Turns = nil
+	return conv
+}
+
+// sortByUpdated 按更新時間由新到舊排序
+func sortByUpdated(conversations []models.Conversation) {
+	sort.Slice(conversations, func(i, j int) bool {
+		return conversations[i].UpdatedAt.After(conversations[j].UpdatedAt)
+	})
+}
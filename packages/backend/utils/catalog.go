@@ -0,0 +1,103 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/rocker15962/llm-web-assistant/packages/backend/config"
+	"github.com/rocker15962/llm-web-assistant/packages/backend/models"
+)
+
+// ErrModelNotAllowed 表示請求的模型不在允許列表中
+var ErrModelNotAllowed = errors.New("模型不在允許列表中")
+
+// defaultContextWindow 未知模型使用的保守上下文大小
+const defaultContextWindow = 8192
+
+// modelCapability 表示已知模型系列的能力
+type modelCapability struct {
+	prefix        string
+	vision        bool
+	contextWindow int
+}
+
+// knownModels 已知模型系列的能力，以前綴匹配，較具體的前綴必須排在前面
+var knownModels = []modelCapability{
+	{prefix: "gpt-4.1", vision: true, contextWindow: 1047576},
+	{prefix: "gpt-4o", vision: true, contextWindow: 128000},
+	{prefix: "gpt-4-turbo", vision: true, contextWindow: 128000},
+	{prefix: "gpt-3.5", vision: false, contextWindow: 16385},
+	{prefix: "o4-mini", vision: true, contextWindow: 200000},
+	{prefix: "o3", vision: true, contextWindow: 200000},
+	{prefix: "o1", vision: true, contextWindow: 200000},
+	{prefix: "claude-", vision: true, contextWindow: 200000},
+	{prefix: "gemini-1.5-pro", vision: true, contextWindow: 2097152},
+	{prefix: "gemini-", vision: true, contextWindow: 1048576},
+	{prefix: "llama3.2-vision", vision: true, contextWindow: 128000},
+	{prefix: "llava", vision: true, contextWindow: 4096},
+	{prefix: "qwen2.5vl", vision: true, contextWindow: 128000},
+	{prefix: "gemma3", vision: true, contextWindow: 128000},
+	{prefix: "llama3", vision: false, contextWindow: 128000},
+	{prefix: "qwen", vision: false, contextWindow: 32768},
+	{prefix: "mistral", vision: false, contextWindow: 32768},
+}
+
+// webSearchProviders 支援網絡搜索工具的提供者
+var webSearchProviders = map[string]bool{
+	ProviderOpenAI:    true,
+	ProviderAnthropic: true,
+	ProviderGemini:    true,
+}
+
+// ModelInfoFor 返回模型的能力資訊
+func ModelInfoFor(cfg config.Config, ref config.ModelRef) models.ModelInfo {
+	info := models.ModelInfo{
+		ID:            ref.ID(cfg.LLMProvider),
+		Provider:      ref.Provider,
+		Model:         ref.Model,
+		WebSearch:     webSearchProviders[ref.Provider],
+		ContextWindow: defaultContextWindow,
+		Default:       ref.Provider == cfg.LLMProvider && ref.Model == cfg.LLMModel,
+	}
+
+	// Azure 只有 responses 形式支援網絡搜索
+	if ref.Provider == ProviderAzure {
+		info.WebSearch = cfg.AzureOpenAI.APIShape == azureShapeResponses
+	}
+
+	name := strings.ToLower(ref.Model)
+	for _, known := range knownModels {
+		if strings.HasPrefix(name, known.prefix) {
+			info.Vision = known.vision
+			info.ContextWindow = known.contextWindow
+			break
+		}
+	}
+
+	return info
+}
+
+// ListModels 返回所有允許的模型及其能力
+func ListModels(cfg config.Config) []models.ModelInfo {
+	infos := []models.ModelInfo{}
+	for _, ref := range cfg.AllowedModels() {
+		infos = append(infos, ModelInfoFor(cfg, ref))
+	}
+	return infos
+}
+
+// ResolveModel 根據請求的模型識別碼返回允許的模型，空字串表示主要模型
+func ResolveModel(cfg config.Config, model string) (config.ModelRef, error) {
+	allowed := cfg.AllowedModels()
+	if model == "" {
+		return allowed[0], nil
+	}
+
+	for _, ref := range allowed {
+		if ref.ID(cfg.LLMProvider) == model || ref.Provider+":"+ref.Model == model {
+			return ref, nil
+		}
+	}
+	return config.ModelRef{}, fmt.Errorf("%w: %s", ErrModelNotAllowed, model)
+}
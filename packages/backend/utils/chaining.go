@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/rocker15962/llm-web-assistant/packages/backend/models"
+)
+
+// previousResponseSupporter 由支援 previous_response_id 串接的提供者實現
+type previousResponseSupporter interface {
+	SupportsPreviousResponse() bool
+}
+
+// supportsPreviousResponse 判斷提供者是否能串接上游先前的回應
+func supportsPreviousResponse(provider Provider) bool {
+	supporter, ok := provider.(previousResponseSupporter)
+	return ok && supporter.SupportsPreviousResponse()
+}
+
+// chainsPreviousResponse 判斷是否對提供者使用 previous_response_id 串接先前的回應
+// 回應 ID 只對產生它的提供者和模型有效，故障轉移到其他提供者或模型、
+// 或在歷史中找不到產生它的輪次時，改為完整重播
+func chainsPreviousResponse(req models.AskRequest, provider Provider) bool {
+	if req.PreviousResponseID == "" || !supportsPreviousResponse(provider) {
+		return false
+	}
+	for i := len(req.History) - 1; i >= 0; i-- {
+		if turn := req.History[i]; turn.ResponseID == req.PreviousResponseID {
+			return turn.Provider == provider.Name() && turn.Model == provider.Model()
+		}
+	}
+	return false
+}
+
+// promptFor 為提供者構建提示詞
+// 可以串接先前的回應時只發送新問題，頁面內容、截圖和歷史已經保存在上游的回應鏈中
+func promptFor(ctx context.Context, req models.AskRequest, provider Provider) Prompt {
+	if !chainsPreviousResponse(req, provider) {
+		if req.PreviousResponseID != "" {
+			LogDebug("提供者 %s (%s) 不能串接先前的回應 %s，使用完整重播", provider.Name(), provider.Model(), req.PreviousResponseID)
+		}
+		req.PreviousResponseID = ""
+		return buildPrompt(ctx, req, provider)
+	}
+
+	LogDebug("串接先前的回應 %s，省略頁面內容和截圖", req.PreviousResponseID)
+	text := fmt.Sprintf("我的問題是：%s", req.Question)
+	// 每輪的選取文字可能不同，仍需隨新問題發送
+	if selected, selectionContext := selectionOf(req); selected != "" {
+		section, _ := buildSelection(selected, selectionContext, pageContextBudgetFor(provider))
+		text += "\n\n" + section
+	}
+	return Prompt{
+		// 重新附上系統提示詞，讓簡單/詳細模式的切換生效
+		System: buildSystemPrompt(req),
+		Messages: []PromptMessage{
+			{Role: "user", Text: text},
+		},
+		MaxOutputTokens:    maxOutputTokens(req),
+		Temperature:        0.7,
+		UseWebSearch:       req.UseWebSearch,
+		PreviousResponseID: req.PreviousResponseID,
+	}
+}
+
+// withReplayFallback 在上游找不到先前的回應時（例如已過期），改用完整重播再試一次
+func withReplayFallback(req models.AskRequest, call providerCall) providerCall {
+	return func(ctx context.Context, provider Provider, prompt Prompt) (ProviderResult, error) {
+		result, err := call(ctx, provider, prompt)
+		if err == nil || prompt.PreviousResponseID == "" || !isPreviousResponseError(err) {
+			return result, err
+		}
+
+		LogWarning("上游找不到先前的回應 %s，回退為完整重播", prompt.PreviousResponseID)
+		req.PreviousResponseID = ""
+		return call(ctx, provider, buildPrompt(ctx, req, provider))
+	}
+}
+
+// isPreviousResponseError 判斷錯誤是否因為 previous_response_id 無效
+// 已經輸出部分內容的串流不能重播，即使底層是 APIError
+func isPreviousResponseError(err error) bool {
+	var partial *errPartialStream
+	if errors.As(err, &partial) {
+		return false
+	}
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	if apiErr.StatusCode != http.StatusBadRequest && apiErr.StatusCode != http.StatusNotFound {
+		return false
+	}
+	return strings.Contains(apiErr.Code, "previous_response") ||
+		strings.Contains(strings.ToLower(apiErr.Message), "previous response")
+}
@@ -0,0 +1,125 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/rocker15962/llm-web-assistant/packages/backend/config"
+	"github.com/rocker15962/llm-web-assistant/packages/backend/models"
+)
+
+// chainedRequest 返回接續 resp_1 的請求，resp_1 由 openai 的 gpt-a 產生
+func chainedRequest() models.AskRequest {
+	return models.AskRequest{
+		Question:           "接著呢？",
+		PreviousResponseID: "resp_1",
+		History: []models.ConversationTurn{
+			{Question: "第一題", Answer: "第一個回答", Provider: ProviderOpenAI, Model: "gpt-a", ResponseID: "resp_1"},
+		},
+	}
+}
+
+func TestChainsPreviousResponse(t *testing.T) {
+	unknown := chainedRequest()
+	unknown.PreviousResponseID = "resp_other"
+
+	tests := []struct {
+		name     string
+		req      models.AskRequest
+		provider Provider
+		want     bool
+	}{
+		{"same provider and model", chainedRequest(), &OpenAIResponsesProvider{cfg: ProviderConfig{Model: "gpt-a"}}, true},
+		{"other model", chainedRequest(), &OpenAIResponsesProvider{cfg: ProviderConfig{Model: "gpt-b"}}, false},
+		{"unsupported provider", chainedRequest(), &OpenAIChatProvider{cfg: ProviderConfig{Model: "gpt-a"}}, false},
+		{"other provider", chainedRequest(), &AzureOpenAIProvider{cfg: ProviderConfig{Azure: config.AzureOpenAIConfig{Deployment: "gpt-a", APIShape: azureShapeResponses}}}, false},
+		{"not in history", unknown, &OpenAIResponsesProvider{cfg: ProviderConfig{Model: "gpt-a"}}, false},
+		{"no previous response", models.AskRequest{}, &OpenAIResponsesProvider{cfg: ProviderConfig{Model: "gpt-a"}}, false},
+	}
+
+	for _, tt := range tests {
+		if got := chainsPreviousResponse(tt.req, tt.provider); got != tt.want {
+			t.Errorf("%s: chainsPreviousResponse = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPromptForChaining(t *testing.T) {
+	req := chainedRequest()
+
+	chained := promptFor(context.Background(), req, &OpenAIResponsesProvider{cfg: ProviderConfig{Model: "gpt-a"}})
+	if chained.PreviousResponseID != "resp_1" || len(chained.Messages) != 1 {
+		t.Errorf("chained prompt = %+v, want only the new question with resp_1", chained)
+	}
+
+	// 其他模型不能串接，必須重播歷史
+	replayed := promptFor(context.Background(), req, &OpenAIResponsesProvider{cfg: ProviderConfig{Model: "gpt-b"}})
+	if replayed.PreviousResponseID != "" || len(replayed.Messages) != 3 {
+		t.Errorf("replayed prompt = %+v, want history and question without previous response", replayed)
+	}
+}
+
+func TestWithReplayFallback(t *testing.T) {
+	expired := &APIError{StatusCode: 400, Code: "previous_response_not_found"}
+	provider := &OpenAIResponsesProvider{cfg: ProviderConfig{Model: "gpt-a"}}
+
+	tests := []struct {
+		name      string
+		prompt    Prompt
+		err       error
+		wantCalls int
+		wantErr   bool
+	}{
+		{"success", Prompt{PreviousResponseID: "resp_1"}, nil, 1, false},
+		{"expired response", Prompt{PreviousResponseID: "resp_1"}, expired, 2, false},
+		{"expired message", Prompt{PreviousResponseID: "resp_1"}, &APIError{StatusCode: 404, Message: "Previous response with id 'resp_1' not found."}, 2, false},
+		{"not chained", Prompt{}, expired, 1, true},
+		{"other error", Prompt{PreviousResponseID: "resp_1"}, &APIError{StatusCode: 500}, 1, true},
+		{"partial stream", Prompt{PreviousResponseID: "resp_1"}, &errPartialStream{err: expired}, 1, true},
+	}
+
+	for _, tt := range tests {
+		var prompts []Prompt
+		call := func(ctx context.Context, provider Provider, prompt Prompt) (ProviderResult, error) {
+			prompts = append(prompts, prompt)
+			if len(prompts) == 1 && tt.err != nil {
+				return ProviderResult{}, tt.err
+			}
+			return ProviderResult{Answer: "回答"}, nil
+		}
+
+		_, err := withReplayFallback(chainedRequest(), call)(context.Background(), provider, tt.prompt)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+		if len(prompts) != tt.wantCalls {
+			t.Errorf("%s: calls = %d, want %d", tt.name, len(prompts), tt.wantCalls)
+			continue
+		}
+		// 重播時不再帶有 previous_response_id，並包含完整歷史
+		if retry := prompts[len(prompts)-1]; tt.wantCalls == 2 && (retry.PreviousResponseID != "" || len(retry.Messages) != 3) {
+			t.Errorf("%s: retry prompt = %+v, want full replay", tt.name, retry)
+		}
+	}
+}
+
+func TestIsPreviousResponseError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&APIError{StatusCode: 400, Code: "previous_response_not_found"}, true},
+		{fmt.Errorf("呼叫失敗: %w", &APIError{StatusCode: 404, Message: "Previous response not found"}), true},
+		{&APIError{StatusCode: 500, Code: "previous_response_not_found"}, false},
+		{&APIError{StatusCode: 400, Message: "invalid model"}, false},
+		{errors.New("previous response"), false},
+	}
+
+	for _, tt := range tests {
+		if got := isPreviousResponseError(tt.err); got != tt.want {
+			t.Errorf("isPreviousResponseError(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
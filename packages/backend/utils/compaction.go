@@ -0,0 +1,197 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/rocker15962/llm-web-assistant/packages/backend/config"
+	"github.com/rocker15962/llm-web-assistant/packages/backend/models"
+)
+
+const (
+	// compactionThreshold 估計的提示詞超過可用上下文的此比例時壓縮歷史
+	compactionThreshold = 0.9
+	// imageTokenEstimate 每張圖片粗略估計的 token 數
+	imageTokenEstimate = 1000
+	// messageTokenOverhead 每條消息的格式開銷
+	messageTokenOverhead = 4
+	// summaryMaxOutputTokens 摘要的最大輸出 token 數
+	summaryMaxOutputTokens = 800
+)
+
+// calibrationSmoothing 每次測量對校準係數的影響權重
+const calibrationSmoothing = 0.3
+
+// promptCalibration 記錄各模型實際 PromptTokens 與本地估計值的比例
+// 本地估計只是近似值，以上游回報的用量校準後才用來判斷是否超出上下文
+var promptCalibration = struct {
+	sync.Mutex
+	ratios map[string]float64
+}{ratios: map[string]float64{}}
+
+// calibrationKey 返回校準係數的鍵
+func calibrationKey(providerName, model string) string {
+	return providerName + ":" + model
+}
+
+// calibrationRatio 返回模型的實際 token 數與 EstimateTokens 估計值的比例，
+// 尚未測量時使用提供者分詞器的大致比例
+func calibrationRatio(providerName, model string) float64 {
+	promptCalibration.Lock()
+	defer promptCalibration.Unlock()
+
+	if ratio, ok := promptCalibration.ratios[calibrationKey(providerName, model)]; ok {
+		return ratio
+	}
+	return tokenScaleFor(providerName)
+}
+
+// recordCalibration 以上游回報的 PromptTokens 更新模型的校準係數
+func recordCalibration(providerName, model string, estimated, measured int) {
+	if estimated <= 0 || measured <= 0 {
+		return
+	}
+
+	ratio := float64(measured) / float64(estimated)
+	// 限制在合理範圍，避免單次異常測量造成過度壓縮
+	if ratio < 0.5 {
+		ratio = 0.5
+	} else if ratio > 3 {
+		ratio = 3
+	}
+
+	promptCalibration.Lock()
+	defer promptCalibration.Unlock()
+
+	key := calibrationKey(providerName, model)
+	if previous, ok := promptCalibration.ratios[key]; ok {
+		ratio = previous + calibrationSmoothing*(ratio-previous)
+	}
+	promptCalibration.ratios[key] = ratio
+}
+
+// withCalibration 在每次成功呼叫後記錄提示詞的實際 token 數
+func withCalibration(call providerCall) providerCall {
+	return func(ctx context.Context, provider Provider, prompt Prompt) (ProviderResult, error) {
+		result, err := call(ctx, provider, prompt)
+		// 串接先前回應時上游會計入整條回應鏈，無法與本地估計比較
+		if err == nil && prompt.PreviousResponseID == "" {
+			recordCalibration(provider.Name(), provider.Model(), estimatePromptTokens(prompt), result.Usage.PromptTokens)
+		}
+		return result, err
+	}
+}
+
+// estimatePromptTokens 以 EstimateTokens 估計提示詞的 token 數，乘以 calibrationRatio 換算為模型的 token 數
+func estimatePromptTokens(prompt Prompt) int {
+	tokens := EstimateTokens(prompt.System) + messageTokenOverhead
+	for _, msg := range prompt.Messages {
+		tokens += EstimateTokens(msg.Text) + messageTokenOverhead
+		tokens += len(msg.Images) * imageTokenEstimate
+	}
+	return tokens
+}
+
+// compactHistory 在歷史加上頁面內容會超出模型上下文時，
+// 將較早的輪次以 LLM 摘要為滾動摘要，只逐字保留預算內最近的輪次
+// 返回壓縮後的請求以及摘要呼叫的 token 使用量
+func compactHistory(ctx context.Context, cfg config.Config, ref config.ModelRef, req models.AskRequest) (models.AskRequest, models.TokenUsage, error) {
+	var usage models.TokenUsage
+	if len(req.History) == 0 {
+		return req, usage, nil
+	}
+
+	provider, err := NewProviderFor(cfg, ref)
+	if err != nil {
+		return req, usage, err
+	}
+
+	// 串接上游回應時不會重播歷史
+	if chainsPreviousResponse(req, provider) {
+		return req, usage, nil
+	}
+
+	prompt := buildPrompt(ctx, req, provider)
+	estimated := float64(estimatePromptTokens(prompt)) * calibrationRatio(provider.Name(), provider.Model())
+	available := ModelInfoFor(cfg, ref).ContextWindow - prompt.MaxOutputTokens
+	if estimated <= float64(available)*compactionThreshold {
+		return req, usage, nil
+	}
+
+	kept := TrimHistory(req.History, cfg.HistoryBudget)
+	older := req.History[:len(req.History)-len(kept)]
+	if len(older) == 0 {
+		LogWarning("提示詞估計約 %.0f tokens，超出模型上下文，但沒有可壓縮的歷史", estimated)
+		return req, usage, nil
+	}
+
+	LogInfo("提示詞估計約 %.0f tokens（上下文可用 %d），將較早的 %d 輪對話壓縮為摘要", estimated, available, len(older))
+
+	summary := req.HistorySummary
+	// 每次只摘要上下文可容納的輪次，逐步滾動合併成一份摘要
+	chunkBudget := available / 2
+	for start := 0; start < len(older); {
+		end, used := start, EstimateTokens(summary)
+		for end < len(older) {
+			used += EstimateTokens(older[end].Question) + EstimateTokens(older[end].Answer)
+			if used > chunkBudget && end > start {
+				break
+			}
+			end++
+		}
+
+		chunkSummary, chunkUsage, err := summarizeTurns(ctx, cfg, ref, summary, older[start:end])
+		if err != nil {
+			return req, usage, fmt.Errorf("壓縮對話歷史失敗: %w", err)
+		}
+		summary = chunkSummary
+		addUsage(&usage, chunkUsage)
+		start = end
+	}
+
+	req.HistorySummary = summary
+	req.SummarizedTurns += len(older)
+	req.History = kept
+	return req, usage, nil
+}
+
+// summarizeTurns 將先前的摘要和新的輪次合併為一份摘要
+func summarizeTurns(ctx context.Context, cfg config.Config, ref config.ModelRef, summary string, turns []models.ConversationTurn) (string, models.TokenUsage, error) {
+	var transcript strings.Builder
+	if summary != "" {
+		transcript.WriteString("先前的摘要：\n")
+		transcript.WriteString(summary)
+		transcript.WriteString("\n\n")
+	}
+	transcript.WriteString("新的對話：\n")
+	for _, turn := range turns {
+		fmt.Fprintf(&transcript, "用戶：%s\n助手：%s\n\n", turn.Question, turn.Answer)
+	}
+
+	prompt := Prompt{
+		System: `你負責壓縮對話歷史。
+請將先前的摘要與新的對話合併為一份簡潔的摘要，供後續對話參考。
+保留用戶關心的主題、重要的事實、數據和結論，以及尚未解決的問題。
+只輸出摘要本身，不要加入額外說明。`,
+		Messages:        []PromptMessage{{Role: "user", Text: transcript.String()}},
+		MaxOutputTokens: summaryMaxOutputTokens,
+		Temperature:     0.3,
+	}
+
+	result, err := callWithFailover(ctx, cfg, ref, func(Provider) Prompt {
+		return prompt
+	}, callProvider)
+	if err != nil {
+		return "", models.TokenUsage{}, err
+	}
+	return strings.TrimSpace(result.Answer), result.Usage, nil
+}
+
+// addUsage 將 token 使用量累加到總量
+func addUsage(total *models.TokenUsage, usage models.TokenUsage) {
+	total.PromptTokens += usage.PromptTokens
+	total.CompletionTokens += usage.CompletionTokens
+	total.TotalTokens += usage.TotalTokens
+}
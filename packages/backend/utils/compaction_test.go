@@ -0,0 +1,139 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rocker15962/llm-web-assistant/packages/backend/config"
+	"github.com/rocker15962/llm-web-assistant/packages/backend/models"
+)
+
+// summaryServer 模擬 Ollama，每次呼叫返回編號遞增的摘要，並記錄收到的對話內容
+type summaryServer struct {
+	mu         sync.Mutex
+	transcript []string
+}
+
+func (s *summaryServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req ollamaRequest
+	_ = json.NewDecoder(r.Body).Decode(&req)
+
+	s.mu.Lock()
+	s.transcript = append(s.transcript, req.Messages[len(req.Messages)-1].Content)
+	n := len(s.transcript)
+	s.mu.Unlock()
+
+	fmt.Fprintf(w, `{"message":{"role":"assistant","content":"摘要%d"},"prompt_eval_count":10,"eval_count":5}`, n)
+}
+
+// longHistory 返回 n 輪每輪約 1000 tokens 的對話
+func longHistory(n int) []models.ConversationTurn {
+	history := make([]models.ConversationTurn, n)
+	for i := range history {
+		history[i] = models.ConversationTurn{
+			Question: fmt.Sprintf("問題%d", i),
+			Answer:   strings.Repeat("a", 4000),
+		}
+	}
+	return history
+}
+
+// compactionConfig 返回使用測試伺服器、上下文為默認 8192 tokens 的 ollama 配置
+func compactionConfig(endpoint string) (config.Config, config.ModelRef) {
+	ref := config.ModelRef{Provider: ProviderOllama, Model: "compaction-test"}
+	return config.Config{
+		LLMProvider:    ref.Provider,
+		LLMModel:       ref.Model,
+		LLMApiEndpoint: endpoint,
+		LLMTimeout:     5 * time.Second,
+		HistoryBudget:  1500,
+	}, ref
+}
+
+func TestCompactHistory(t *testing.T) {
+	server := &summaryServer{}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	cfg, ref := compactionConfig(ts.URL)
+	req := models.AskRequest{Question: "最後的問題", History: longHistory(10)}
+
+	compacted, usage, err := compactHistory(context.Background(), cfg, ref, req)
+	if err != nil {
+		t.Fatalf("compactHistory: %v", err)
+	}
+
+	calls := len(server.transcript)
+	if calls < 2 {
+		t.Fatalf("summary calls = %d, want the older turns summarized in several chunks", calls)
+	}
+	if compacted.HistorySummary != fmt.Sprintf("摘要%d", calls) {
+		t.Errorf("HistorySummary = %q, want the last rolling summary", compacted.HistorySummary)
+	}
+	if compacted.SummarizedTurns != 9 || len(compacted.History) != 1 {
+		t.Errorf("SummarizedTurns = %d, History = %d turns, want 9 and 1", compacted.SummarizedTurns, len(compacted.History))
+	}
+	if compacted.History[0].Question != "問題9" {
+		t.Errorf("kept turn = %q, want the latest turn", compacted.History[0].Question)
+	}
+	if usage.TotalTokens != 15*calls {
+		t.Errorf("usage = %+v, want %d total tokens", usage, 15*calls)
+	}
+
+	// 後續的摘要呼叫帶有先前的摘要，逐步滾動合併
+	for i := 1; i < calls; i++ {
+		if !strings.Contains(server.transcript[i], fmt.Sprintf("摘要%d", i)) {
+			t.Errorf("call %d does not include the previous summary", i+1)
+		}
+	}
+}
+
+func TestCompactHistoryWithinContext(t *testing.T) {
+	server := &summaryServer{}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	cfg, ref := compactionConfig(ts.URL)
+	req := models.AskRequest{Question: "問題", History: longHistory(2)}
+
+	compacted, _, err := compactHistory(context.Background(), cfg, ref, req)
+	if err != nil {
+		t.Fatalf("compactHistory: %v", err)
+	}
+	if len(server.transcript) != 0 || len(compacted.History) != 2 || compacted.HistorySummary != "" {
+		t.Errorf("short history was compacted: %d calls, %d turns kept", len(server.transcript), len(compacted.History))
+	}
+}
+
+func TestRecordCalibration(t *testing.T) {
+	model := "calibration-test"
+	reset := func() {
+		promptCalibration.Lock()
+		delete(promptCalibration.ratios, calibrationKey(ProviderAnthropic, model))
+		promptCalibration.Unlock()
+	}
+	reset()
+	t.Cleanup(reset)
+
+	if got := calibrationRatio(ProviderAnthropic, model); got != tokenScaleFor(ProviderAnthropic) {
+		t.Errorf("initial ratio = %v, want the provider scale", got)
+	}
+
+	recordCalibration(ProviderAnthropic, model, 100, 200)
+	if got := calibrationRatio(ProviderAnthropic, model); got != 2 {
+		t.Errorf("ratio = %v, want 2", got)
+	}
+
+	// 後續測量平滑合併，異常值限制在 3 倍以內
+	recordCalibration(ProviderAnthropic, model, 100, 1000)
+	if got, want := calibrationRatio(ProviderAnthropic, model), 2+calibrationSmoothing*(3-2); got != want {
+		t.Errorf("ratio = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,411 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/rocker15962/llm-web-assistant/packages/backend/config"
+	"github.com/rocker15962/llm-web-assistant/packages/backend/models"
+	"github.com/rocker15962/llm-web-assistant/packages/backend/search"
+)
+
+const (
+	// truncatedMarker 內容被截斷時附加的標記
+	truncatedMarker = "…(內容已截斷)"
+	// pdfPassageTokens PDF 每個段落的 token 上限，用於相關度排序
+	pdfPassageTokens = 300
+)
+
+// ContextRequest 是構建頁面上下文所需的資料
+type ContextRequest struct {
+	Question    string
+	URL         string
+	PageContent *models.PageContent
+	Budget      int // 頁面上下文的 token 預算
+}
+
+// ContextBuilder 將頁面內容整理成不超過 token 預算的提示詞文本
+type ContextBuilder interface {
+	BuildContext(ctx context.Context, req ContextRequest) (string, error)
+}
+
+// 頁面上下文構建策略
+const (
+	ContextStrategySequential = "sequential"
+	ContextStrategyRelevance  = "relevance"
+	ContextStrategyEmbedding  = "embedding"
+)
+
+// contextBuilders 各策略的頁面上下文構建器
+var contextBuilders = map[string]ContextBuilder{
+	ContextStrategySequential: sequentialContextBuilder{},
+	ContextStrategyRelevance:  relevanceContextBuilder{},
+	ContextStrategyEmbedding:  embeddingContextBuilder{},
+}
+
+// contextBuilderFor 返回策略對應的構建器，未知策略使用相關度排序
+func contextBuilderFor(strategy string) ContextBuilder {
+	if builder, ok := contextBuilders[strategy]; ok {
+		return builder
+	}
+	return contextBuilders[ContextStrategyRelevance]
+}
+
+// contextBudgeter 由長上下文的提供者實現，在未配置預算時放寬頁面上下文預算
+type contextBudgeter interface {
+	PageContextBudget() int
+}
+
+// pageContextBudgetFor 返回提供者適用的頁面上下文 token 預算
+// 預算不超過模型上下文的一半，並以實測的 PromptTokens 校準本地估計
+func pageContextBudgetFor(provider Provider) int {
+	cfg := config.LoadConfig()
+
+	// 明確配置的預算優先於提供者的默認值
+	budget := cfg.ContextBudget
+	if budget == 0 {
+		budget = config.DefaultContextBudget
+		if budgeter, ok := provider.(contextBudgeter); ok {
+			budget = budgeter.PageContextBudget()
+		}
+	}
+
+	ref := config.ModelRef{Provider: provider.Name(), Model: provider.Model()}
+	if limit := ModelInfoFor(cfg, ref).ContextWindow / 2; budget > limit {
+		budget = limit
+	}
+
+	return int(float64(budget) / calibrationRatio(provider.Name(), provider.Model()))
+}
+
+// buildPageContext 以配置的構建器整理頁面內容
+// 失敗時（例如嵌入 API 無法使用）回退為不依賴外部服務的關鍵字相關度排序
+func buildPageContext(ctx context.Context, req ContextRequest) string {
+	builder := contextBuilderFor(config.LoadConfig().ContextMode)
+	text, err := builder.BuildContext(ctx, req)
+	if err != nil {
+		LogWarning("構建頁面上下文失敗，改用關鍵字相關度排序: %v", err)
+		text, _ = relevanceContextBuilder{}.BuildContext(ctx, req)
+	}
+	return text
+}
+
+// sequentialContextBuilder 按頁面順序填入各部分內容，直到用完預算
+type sequentialContextBuilder struct{}
+
+// BuildContext 構建頁面上下文
+func (sequentialContextBuilder) BuildContext(_ context.Context, req ContextRequest) (string, error) {
+	sections := splitPageContent(req.PageContent)
+
+	var b strings.Builder
+	remaining := req.Budget - writePageHeader(&b, sections, req.Budget)
+	linkBudget := linkReserve(sections.links, remaining)
+
+	if len(sections.passages) > 0 {
+		section, used := fillSection("內容摘要：\n", sections.passages, remaining-linkBudget, "%s\n\n", "...(更多內容)\n")
+		b.WriteString(section)
+		remaining -= used
+	}
+	writeLinks(&b, sections.links, remaining)
+
+	return b.String(), nil
+}
+
+// pageSections 是整理後的頁面內容，passages 可按相關度排序
+type pageSections struct {
+	selected string   // 使用者選取的文字
+	info     []string // 語言和 meta 標籤
+	headings []string
+	passages []string // 正文段落、列表和表格
+	links    []string
+}
+
+// splitPageContent 將頁面內容整理為提示詞的各個部分
+// 正文依序使用服務端提取的 Markdown、PDF 頁面、段落列表和表格，都沒有時切分 bodyText
+func splitPageContent(page *models.PageContent) pageSections {
+	var s pageSections
+	if page == nil {
+		return s
+	}
+
+	s.selected = page.SelectedText
+
+	if page.Language != "" {
+		s.info = append(s.info, "語言："+page.Language)
+	}
+	keys := make([]string, 0, len(page.Meta))
+	for key := range page.Meta {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s.info = append(s.info, key+"："+page.Meta[key])
+	}
+
+	for _, heading := range page.Headings {
+		if heading.Level > 0 {
+			s.headings = append(s.headings, strings.Repeat("#", heading.Level)+" "+heading.Text)
+		} else {
+			s.headings = append(s.headings, "- "+heading.Text)
+		}
+	}
+
+	switch {
+	case page.Markdown != "":
+		s.passages = splitMarkdownBlocks(page.Markdown)
+	case len(page.Pages) > 0:
+		for i, text := range page.Pages {
+			s.passages = append(s.passages, pagePassages(i+1, text)...)
+		}
+	default:
+		s.passages = append(s.passages, page.Paragraphs...)
+		for _, list := range page.Lists {
+			s.passages = append(s.passages, listPassage(list))
+		}
+		for _, table := range page.Tables {
+			s.passages = append(s.passages, tablePassage(table))
+		}
+	}
+	if len(s.passages) == 0 {
+		s.passages = splitParagraphs(page.BodyText)
+	}
+
+	for _, link := range page.Links {
+		s.links = append(s.links, fmt.Sprintf("%s（%s）", link.Text, link.Href))
+	}
+	return s
+}
+
+// pagePassages 將 PDF 一頁的文字按行合併為段落，每段標上頁碼以便回答引用出處
+func pagePassages(number int, text string) []string {
+	marker := fmt.Sprintf("[第 %d 頁] ", number)
+	passages := []string{}
+	for _, chunk := range chunkPassages(splitParagraphs(text), pdfPassageTokens) {
+		passages = append(passages, marker+strings.ReplaceAll(chunk, "\n", " "))
+	}
+	return passages
+}
+
+// listPassage 將列表轉為 Markdown 文本
+func listPassage(list models.PageList) string {
+	lines := make([]string, len(list.Items))
+	for i, item := range list.Items {
+		if list.Ordered {
+			lines[i] = fmt.Sprintf("%d. %s", i+1, item)
+		} else {
+			lines[i] = "- " + item
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// tablePassage 將表格轉為 Markdown 表格，沒有表頭時以第一列作為表頭
+func tablePassage(table models.PageTable) string {
+	rows := table.Rows
+	headers := table.Headers
+	if len(headers) == 0 && len(rows) > 0 {
+		headers, rows = rows[0], rows[1:]
+	}
+
+	columns := len(headers)
+	for _, row := range rows {
+		if len(row) > columns {
+			columns = len(row)
+		}
+	}
+
+	formatRow := func(cells []string) string {
+		padded := make([]string, columns)
+		for i := range padded {
+			if i < len(cells) {
+				padded[i] = strings.ReplaceAll(strings.Join(strings.Fields(cells[i]), " "), "|", `\|`)
+			}
+		}
+		return "| " + strings.Join(padded, " | ") + " |"
+	}
+
+	var lines []string
+	if table.Caption != "" {
+		lines = append(lines, "表格："+table.Caption)
+	}
+	lines = append(lines, formatRow(headers), "|"+strings.Repeat(" --- |", columns))
+	for _, row := range rows {
+		lines = append(lines, formatRow(row))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// writePageHeader 依序寫入選取文字、頁面資訊和標題，返回使用的 token 數
+// 選取文字最多使用四分之一的預算，頁面資訊八分之一，標題為剩餘預算的四分之一
+func writePageHeader(b *strings.Builder, s pageSections, budget int) int {
+	used := 0
+	write := func(section string, n int) {
+		if section != "" {
+			b.WriteString(section)
+			b.WriteString("\n")
+			used += n
+		}
+	}
+
+	if s.selected != "" {
+		write(fillSection("使用者選取的文字：\n", []string{s.selected}, budget/4, "%s\n", ""))
+	}
+	write(fillSection("頁面資訊：\n", s.info, budget/8, "- %s\n", ""))
+	write(fillSection("標題：\n", s.headings, (budget-used)/4, "%s\n", "...(更多標題)\n"))
+
+	return used
+}
+
+// linkReserve 返回為連結保留的預算，最多使用剩餘預算的八分之一
+func linkReserve(links []string, remaining int) int {
+	total := 0
+	for _, link := range links {
+		total += EstimateTokens(link) + 1
+	}
+	if limit := remaining / 8; total > limit {
+		return limit
+	}
+	return total
+}
+
+// writeLinks 在預算內寫入頁面連結
+func writeLinks(b *strings.Builder, links []string, budget int) {
+	section, _ := fillSection("頁面連結：\n", links, budget, "- %s\n", "...(更多連結)\n")
+	if section != "" && b.Len() > 0 && !strings.HasSuffix(b.String(), "\n\n") {
+		b.WriteString("\n")
+	}
+	b.WriteString(section)
+}
+
+// splitMarkdownBlocks 以空行將 Markdown 切分為區塊，程式碼區塊保持完整
+func splitMarkdownBlocks(text string) []string {
+	blocks := []string{}
+	var current []string
+	inFence := false
+	flush := func() {
+		if block := strings.TrimSpace(strings.Join(current, "\n")); block != "" {
+			blocks = append(blocks, block)
+		}
+		current = nil
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inFence = !inFence
+		}
+		if !inFence && strings.TrimSpace(line) == "" {
+			flush()
+			continue
+		}
+		current = append(current, line)
+	}
+	flush()
+	return blocks
+}
+
+// fillSection 依序填入項目直到用完預算，最後一個放不下的項目按句子截斷
+// 返回區塊文本及使用的 token 數
+func fillSection(header string, items []string, budget int, format, more string) (string, int) {
+	var b strings.Builder
+	used := EstimateTokens(header)
+	if used >= budget {
+		return "", 0
+	}
+	b.WriteString(header)
+
+	written := 0
+	for _, item := range items {
+		entry := fmt.Sprintf(format, item)
+		cost := EstimateTokens(entry)
+		if used+cost <= budget {
+			b.WriteString(entry)
+			used += cost
+			written++
+			continue
+		}
+
+		// 剩餘預算足夠時放入截斷後的項目
+		if remaining := budget - used - EstimateTokens(more); remaining > 20 {
+			if text, _ := TruncateToTokens(item, remaining); strings.TrimSpace(text) != "" {
+				entry = fmt.Sprintf(format, text)
+				b.WriteString(entry)
+				used += EstimateTokens(entry)
+				written++
+			}
+		}
+		b.WriteString(more)
+		used += EstimateTokens(more)
+		break
+	}
+
+	if written == 0 {
+		// 沒有任何項目放得下時省略整個區塊
+		return "", 0
+	}
+	return b.String(), used
+}
+
+// TruncateToTokens 將文本截斷到不超過 token 預算，優先在句子邊界截斷，
+// 其次在空白處，最後在字元邊界，不會切斷 UTF-8 字元
+// 返回截斷後的文本以及是否發生截斷
+func TruncateToTokens(text string, budget int) (string, bool) {
+	if EstimateTokens(text) <= budget {
+		return text, false
+	}
+
+	budget -= EstimateTokens(truncatedMarker)
+	if budget <= 0 {
+		return "", true
+	}
+
+	runes := []rune(text)
+	cut := runeCutForTokens(runes, budget)
+
+	// 在後半段中尋找最後的句子或空白邊界
+	sentence, space := -1, -1
+	for i := cut - 1; i >= cut/2; i-- {
+		if isSentenceEnd(runes[i]) {
+			sentence = i + 1
+			break
+		}
+		if space < 0 && unicode.IsSpace(runes[i]) {
+			space = i
+		}
+	}
+	switch {
+	case sentence > 0:
+		cut = sentence
+	case space > 0:
+		cut = space
+	}
+
+	return strings.TrimSpace(string(runes[:cut])) + truncatedMarker, true
+}
+
+// runeCutForTokens 返回估計 token 數不超過預算的最長前綴長度（字元數）
+func runeCutForTokens(runes []rune, budget int) int {
+	cjk, other := 0, 0
+	for i, r := range runes {
+		if search.IsCJK(r) {
+			cjk++
+		} else {
+			other++
+		}
+		if cjk+(other+3)/4 > budget {
+			return i
+		}
+	}
+	return len(runes)
+}
+
+// isSentenceEnd 判斷字元是否為句子結尾
+func isSentenceEnd(r rune) bool {
+	switch r {
+	case '。', '！', '？', '；', '.', '!', '?', ';', '\n':
+		return true
+	}
+	return false
+}
@@ -0,0 +1,231 @@
+package utils
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/rocker15962/llm-web-assistant/packages/backend/config"
+)
+
+const (
+	// embeddingChunkTokens 每個檢索片段的目標 token 數
+	embeddingChunkTokens = 200
+	// maxEmbeddingChunks 每個頁面最多嵌入的片段數，避免超長頁面產生大量請求
+	maxEmbeddingChunks = 256
+	// embeddingBatchSize 每次嵌入請求的片段數
+	embeddingBatchSize = 64
+	// maxCachedPages 向量快取最多保存的頁面數
+	maxCachedPages = 64
+)
+
+// pageVectors 保存一個頁面的片段及其向量
+type pageVectors struct {
+	chunks  []string
+	vectors [][]float32
+}
+
+// vectorIndex 是記憶體中的頁面向量快取，以嵌入模型、URL 和內容雜湊為鍵
+// 同一頁面的後續提問只需嵌入問題本身
+type vectorIndex struct {
+	mu    sync.Mutex
+	pages map[string]*pageVectors
+	order []string // 插入順序，超過上限時淘汰最早的頁面
+}
+
+// pageVectorIndex 全局的頁面向量快取
+var pageVectorIndex = &vectorIndex{pages: map[string]*pageVectors{}}
+
+// get 返回快取的頁面向量
+func (idx *vectorIndex) get(key string) (*pageVectors, bool) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	page, ok := idx.pages[key]
+	return page, ok
+}
+
+// put 保存頁面向量，超過上限時淘汰最早的頁面
+func (idx *vectorIndex) put(key string, page *pageVectors) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if _, ok := idx.pages[key]; !ok {
+		idx.order = append(idx.order, key)
+	}
+	idx.pages[key] = page
+
+	for len(idx.order) > maxCachedPages {
+		delete(idx.pages, idx.order[0])
+		idx.order = idx.order[1:]
+	}
+}
+
+// pageVectorKey 返回頁面向量的快取鍵
+func pageVectorKey(embedder Embedder, url string, chunks []string) string {
+	hash := sha256.Sum256([]byte(strings.Join(chunks, "\x00")))
+	return embedder.Name() + "|" + url + "|" + hex.EncodeToString(hash[:])
+}
+
+// embeddingContextBuilder 將頁面切分為片段並嵌入，選取與問題最相似的片段
+type embeddingContextBuilder struct{}
+
+// BuildContext 構建頁面上下文
+func (embeddingContextBuilder) BuildContext(ctx context.Context, req ContextRequest) (string, error) {
+	sections := splitPageContent(req.PageContent)
+	if fitsBudget(sections, req.Budget) {
+		return sequentialContextBuilder{}.BuildContext(ctx, req)
+	}
+
+	chunks := chunkPageContent(sections.passages)
+	if len(chunks) == 0 || strings.TrimSpace(req.Question) == "" {
+		return sequentialContextBuilder{}.BuildContext(ctx, req)
+	}
+
+	cfg := config.LoadConfig()
+	embedder, err := NewEmbedder(cfg)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.LLMTimeout)
+	defer cancel()
+
+	page, err := embedPage(ctx, embedder, req.URL, chunks)
+	if err != nil {
+		return "", fmt.Errorf("嵌入頁面內容失敗: %w", err)
+	}
+
+	question, err := embedder.Embed(ctx, []string{req.Question})
+	if err != nil {
+		return "", fmt.Errorf("嵌入問題失敗: %w", err)
+	}
+
+	scores := make([]float64, len(page.vectors))
+	for i, vector := range page.vectors {
+		scores[i] = cosineSimilarity(question[0], vector)
+	}
+	order := make([]int, len(scores))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return scores[order[a]] > scores[order[b]]
+	})
+
+	sections.passages = page.chunks
+	return assembleRankedContext(sections, order, req.Budget, 0), nil
+}
+
+// embedPage 返回頁面片段的向量，優先使用快取
+func embedPage(ctx context.Context, embedder Embedder, url string, chunks []string) (*pageVectors, error) {
+	key := pageVectorKey(embedder, url, chunks)
+	if page, ok := pageVectorIndex.get(key); ok {
+		LogDebug("使用快取的頁面向量: %s（%d 個片段）", url, len(page.chunks))
+		return page, nil
+	}
+
+	vectors := make([][]float32, 0, len(chunks))
+	for start := 0; start < len(chunks); start += embeddingBatchSize {
+		end := start + embeddingBatchSize
+		if end > len(chunks) {
+			end = len(chunks)
+		}
+		batch, err := embedder.Embed(ctx, chunks[start:end])
+		if err != nil {
+			return nil, err
+		}
+		vectors = append(vectors, batch...)
+	}
+
+	page := &pageVectors{chunks: chunks, vectors: vectors}
+	pageVectorIndex.put(key, page)
+	LogDebug("已嵌入頁面內容: %s（%d 個片段）", url, len(chunks))
+	return page, nil
+}
+
+// chunkPageContent 將段落合併為接近嵌入目標大小的片段
+func chunkPageContent(paragraphs []string) []string {
+	chunks := chunkPassages(paragraphs, embeddingChunkTokens)
+	if len(chunks) > maxEmbeddingChunks {
+		chunks = chunks[:maxEmbeddingChunks]
+	}
+	return chunks
+}
+
+// chunkPassages 依序將段落合併為不超過 limit 個 token 的片段，過長的段落按句子切分
+func chunkPassages(paragraphs []string, limit int) []string {
+	chunks := []string{}
+	var current strings.Builder
+	currentTokens := 0
+	flush := func() {
+		if current.Len() > 0 {
+			chunks = append(chunks, strings.TrimSpace(current.String()))
+			current.Reset()
+			currentTokens = 0
+		}
+	}
+
+	for _, paragraph := range paragraphs {
+		for _, piece := range splitToTokenLimit(paragraph, limit) {
+			tokens := EstimateTokens(piece)
+			if currentTokens > 0 && currentTokens+tokens > limit {
+				flush()
+			}
+			current.WriteString(piece)
+			current.WriteString("\n")
+			currentTokens += tokens
+		}
+	}
+	flush()
+
+	return chunks
+}
+
+// splitToTokenLimit 將文本按句子邊界切分為不超過 limit 個 token 的片段
+func splitToTokenLimit(text string, limit int) []string {
+	pieces := []string{}
+	runes := []rune(strings.TrimSpace(text))
+	for len(runes) > 0 {
+		cut := runeCutForTokens(runes, limit)
+		if cut >= len(runes) {
+			pieces = append(pieces, string(runes))
+			break
+		}
+		// 在後半段尋找句子邊界
+		for i := cut - 1; i >= cut/2; i-- {
+			if isSentenceEnd(runes[i]) {
+				cut = i + 1
+				break
+			}
+		}
+		if cut == 0 {
+			cut = 1
+		}
+		pieces = append(pieces, strings.TrimSpace(string(runes[:cut])))
+		runes = []rune(strings.TrimSpace(string(runes[cut:])))
+	}
+	return pieces
+}
+
+// cosineSimilarity 計算兩個向量的餘弦相似度
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
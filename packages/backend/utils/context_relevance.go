@@ -0,0 +1,162 @@
+package utils
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/rocker15962/llm-web-assistant/packages/backend/search"
+)
+
+// relevanceNeighbors 每個高分段落前後一併放入的段落數
+const relevanceNeighbors = 1
+
+// relevanceContextBuilder 以 BM25 按問題對段落排序，放入最相關的段落及其前後段落
+// 頁面內容能完整放入預算、或問題與所有段落都沒有共同詞項時，回退為依序填入
+type relevanceContextBuilder struct{}
+
+// BuildContext 構建頁面上下文
+func (relevanceContextBuilder) BuildContext(ctx context.Context, req ContextRequest) (string, error) {
+	sections := splitPageContent(req.PageContent)
+	if fitsBudget(sections, req.Budget) {
+		return sequentialContextBuilder{}.BuildContext(ctx, req)
+	}
+
+	scores := search.RankPassages(req.Question, sections.passages)
+	order := rankedOrder(scores)
+	if len(order) == 0 {
+		return sequentialContextBuilder{}.BuildContext(ctx, req)
+	}
+
+	sections.links = rankLinks(req.Question, sections.links)
+	return assembleRankedContext(sections, order, req.Budget, relevanceNeighbors), nil
+}
+
+// assembleRankedContext 按排名選取段落及其前後段落，並按頁面原來的順序輸出
+// order 為依相關度排序的 sections.passages 索引
+func assembleRankedContext(sections pageSections, order []int, budget, neighbors int) string {
+	var b strings.Builder
+	paragraphs := sections.passages
+	remaining := budget - writePageHeader(&b, sections, budget)
+	linkBudget := linkReserve(sections.links, remaining)
+
+	header := "與問題最相關的內容：\n"
+	remaining -= EstimateTokens(header) + linkBudget
+
+	selected := map[int]string{}
+	take := func(i int) bool {
+		if i < 0 || i >= len(paragraphs) {
+			return true
+		}
+		if _, ok := selected[i]; ok {
+			return true
+		}
+		cost := EstimateTokens(paragraphs[i]) + 1
+		if cost <= remaining {
+			selected[i] = paragraphs[i]
+			remaining -= cost
+			return true
+		}
+		// 剩餘預算足夠時放入截斷後的段落
+		if remaining > 20 {
+			text, _ := TruncateToTokens(paragraphs[i], remaining-1)
+			selected[i] = text
+			remaining = 0
+		}
+		return false
+	}
+
+	full := true
+	for _, i := range order {
+		if full = take(i); !full {
+			break
+		}
+		for d := 1; d <= neighbors && full; d++ {
+			full = take(i-d) && take(i+d)
+		}
+		if !full {
+			break
+		}
+	}
+
+	// 相關段落都放入後仍有預算時，從頁面開頭依序補上
+	for i := 0; full && i < len(paragraphs); i++ {
+		full = take(i)
+	}
+
+	indices := make([]int, 0, len(selected))
+	for i := range selected {
+		indices = append(indices, i)
+	}
+	sort.Ints(indices)
+
+	b.WriteString(header)
+	for n, i := range indices {
+		// 標示被省略的段落
+		if (n == 0 && i > 0) || (n > 0 && i > indices[n-1]+1) {
+			b.WriteString("...\n\n")
+		}
+		b.WriteString(selected[i])
+		b.WriteString("\n\n")
+	}
+	if len(indices) > 0 && indices[len(indices)-1] < len(paragraphs)-1 {
+		b.WriteString("...\n\n")
+	}
+
+	writeLinks(&b, sections.links, remaining+linkBudget)
+	return b.String()
+}
+
+// rankLinks 將與問題相關的連結排在前面，其餘保持頁面順序
+func rankLinks(question string, links []string) []string {
+	scores := search.RankPassages(question, links)
+	order := make([]int, len(links))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return scores[order[a]] > scores[order[b]]
+	})
+
+	ranked := make([]string, len(links))
+	for i, index := range order {
+		ranked[i] = links[index]
+	}
+	return ranked
+}
+
+// rankedOrder 返回分數大於零的段落索引，按分數由高到低排序
+func rankedOrder(scores []float64) []int {
+	order := []int{}
+	for i, score := range scores {
+		if score > 0 {
+			order = append(order, i)
+		}
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return scores[order[a]] > scores[order[b]]
+	})
+	return order
+}
+
+// fitsBudget 判斷頁面的所有內容是否能完整放入預算
+func fitsBudget(sections pageSections, budget int) bool {
+	total := EstimateTokens(sections.selected)
+	for _, list := range [][]string{sections.info, sections.headings, sections.passages, sections.links} {
+		for _, text := range list {
+			total += EstimateTokens(text) + 1
+		}
+	}
+	return total <= budget
+}
+
+// splitParagraphs 將純文本按行切分為非空段落
+func splitParagraphs(text string) []string {
+	paragraphs := []string{}
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			paragraphs = append(paragraphs, line)
+		}
+	}
+	return paragraphs
+}
@@ -0,0 +1,47 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncateToTokens(t *testing.T) {
+	long := strings.Repeat("第一句話。", 20) + strings.Repeat("word ", 50)
+
+	tests := []struct {
+		name          string
+		text          string
+		budget        int
+		want          string // 空字串表示只檢查預算與邊界
+		wantTruncated bool
+	}{
+		{"fits", "短文本", 10, "短文本", false},
+		{"exact budget", "你好", 2, "你好", false},
+		{"budget smaller than marker", "你好世界你好世界", 1, "", true},
+		{"cuts at sentence end", "第一句。第二句很長很長很長很長很長", 10, "第一句。" + truncatedMarker, true},
+		{"cuts at space", "alpha beta gamma delta epsilon zeta eta theta iota kappa", 10, "", true},
+		{"long mixed text", long, 40, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, truncated := TruncateToTokens(tt.text, tt.budget)
+			if truncated != tt.wantTruncated {
+				t.Fatalf("truncated = %v, want %v", truncated, tt.wantTruncated)
+			}
+			if tt.want != "" && got != tt.want {
+				t.Errorf("TruncateToTokens = %q, want %q", got, tt.want)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("TruncateToTokens returned invalid UTF-8: %q", got)
+			}
+			if EstimateTokens(got) > tt.budget {
+				t.Errorf("EstimateTokens(result) = %d, exceeds budget %d", EstimateTokens(got), tt.budget)
+			}
+			if truncated && got != "" && !strings.HasSuffix(got, truncatedMarker) {
+				t.Errorf("truncated result %q lacks the marker", got)
+			}
+		})
+	}
+}
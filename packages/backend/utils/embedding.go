@@ -0,0 +1,152 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/rocker15962/llm-web-assistant/packages/backend/config"
+)
+
+// 嵌入 API 的默認端點
+const (
+	defaultOpenAIEmbeddingEndpoint = "https://api.openai.com/v1/embeddings"
+	defaultOllamaEmbeddingEndpoint = "http://localhost:11434/api/embed"
+)
+
+// Embedder 將文本轉換為向量
+type Embedder interface {
+	// Name 返回提供者與模型，用於區分向量快取
+	Name() string
+	// Embed 返回與輸入順序對應的向量
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// NewEmbedder 根據配置創建嵌入提供者
+func NewEmbedder(cfg config.Config) (Embedder, error) {
+	emb := cfg.Embedding
+	if emb.APIKey == "" {
+		emb.APIKey, _ = cfg.ProviderCredentials(emb.Provider)
+	}
+
+	switch emb.Provider {
+	case ProviderOpenAI:
+		if emb.APIKey == "" {
+			return nil, fmt.Errorf("未設置嵌入模型的 API 密鑰")
+		}
+		if emb.Endpoint == "" {
+			emb.Endpoint = defaultOpenAIEmbeddingEndpoint
+		}
+		return &openAIEmbedder{cfg: emb}, nil
+	case ProviderOllama:
+		if emb.Endpoint == "" {
+			emb.Endpoint = defaultOllamaEmbeddingEndpoint
+		}
+		return &ollamaEmbedder{cfg: emb}, nil
+	default:
+		return nil, fmt.Errorf("不支援的嵌入提供者: %s", emb.Provider)
+	}
+}
+
+// openAIEmbedder 使用 OpenAI Embeddings API
+type openAIEmbedder struct {
+	cfg config.EmbeddingConfig
+}
+
+// Name 返回提供者與模型
+func (e *openAIEmbedder) Name() string {
+	return ProviderOpenAI + ":" + e.cfg.Model
+}
+
+// Embed 呼叫 /v1/embeddings
+func (e *openAIEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	httpReq, err := postJSON(ctx, e.cfg.Endpoint, map[string]interface{}{
+		"model": e.cfg.Model,
+		"input": texts,
+	})
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+e.cfg.APIKey)
+
+	var resp struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := doEmbeddingRequest(ProviderOpenAI, httpReq, &resp); err != nil {
+		return nil, err
+	}
+
+	vectors := make([][]float32, len(texts))
+	for _, item := range resp.Data {
+		if item.Index >= 0 && item.Index < len(vectors) {
+			vectors[item.Index] = item.Embedding
+		}
+	}
+	return vectors, checkEmbeddings(vectors)
+}
+
+// ollamaEmbedder 使用本地 Ollama 的 /api/embed
+type ollamaEmbedder struct {
+	cfg config.EmbeddingConfig
+}
+
+// Name 返回提供者與模型
+func (e *ollamaEmbedder) Name() string {
+	return ProviderOllama + ":" + e.cfg.Model
+}
+
+// Embed 呼叫 /api/embed
+func (e *ollamaEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	httpReq, err := postJSON(ctx, e.cfg.Endpoint, map[string]interface{}{
+		"model": e.cfg.Model,
+		"input": texts,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		Embeddings [][]float32 `json:"embeddings"`
+	}
+	if err := doEmbeddingRequest(ProviderOllama, httpReq, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("嵌入數量不符: 預期 %d，實際 %d", len(texts), len(resp.Embeddings))
+	}
+	return resp.Embeddings, checkEmbeddings(resp.Embeddings)
+}
+
+// doEmbeddingRequest 發送嵌入請求並解析響應
+func doEmbeddingRequest(provider string, httpReq *http.Request, out interface{}) error {
+	LogDebug("發送請求到嵌入 API (%s): %s", provider, httpReq.URL.Redacted())
+	resp, err := llmHTTPClient.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return parseAPIError(provider, resp.StatusCode, body)
+	}
+	return json.Unmarshal(body, out)
+}
+
+// checkEmbeddings 確認每個輸入都有向量
+func checkEmbeddings(vectors [][]float32) error {
+	for i, vector := range vectors {
+		if len(vector) == 0 {
+			return fmt.Errorf("第 %d 段文本沒有返回嵌入向量", i)
+		}
+	}
+	return nil
+}
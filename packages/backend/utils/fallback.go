@@ -0,0 +1,211 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/rocker15962/llm-web-assistant/packages/backend/config"
+)
+
+// 故障轉移規則
+const (
+	FailoverRateLimit   = "429"     // 速率限制
+	FailoverServerError = "5xx"     // 伺服器錯誤
+	FailoverClientError = "4xx"     // 所有客戶端錯誤
+	FailoverTimeout     = "timeout" // 超時
+	FailoverNetwork     = "network" // 連線失敗等網絡錯誤
+	FailoverEmpty       = "empty"   // 響應成功但沒有回答
+)
+
+// FailoverPolicy 決定哪些錯誤會觸發切換到下一個提供者
+type FailoverPolicy struct {
+	rules map[string]bool
+}
+
+// NewFailoverPolicy 根據規則列表建立故障轉移策略，
+// 規則可以是上述類別或具體的 HTTP 狀態碼（例如 404）
+func NewFailoverPolicy(rules []string) FailoverPolicy {
+	policy := FailoverPolicy{rules: map[string]bool{}}
+	for _, rule := range rules {
+		policy.rules[strings.ToLower(strings.TrimSpace(rule))] = true
+	}
+	return policy
+}
+
+// ShouldFailover 判斷錯誤是否應該觸發故障轉移
+func (p FailoverPolicy) ShouldFailover(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		code := apiErr.StatusCode
+		switch {
+		case p.rules[strconv.Itoa(code)]:
+			return true
+		case code >= 500:
+			return p.rules[FailoverServerError]
+		case code >= 400:
+			return p.rules[FailoverClientError]
+		}
+		return false
+	}
+
+	if errors.Is(err, ErrEmptyAnswer) {
+		return p.rules[FailoverEmpty]
+	}
+	if isTimeoutError(err) {
+		return p.rules[FailoverTimeout]
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return p.rules[FailoverNetwork]
+	}
+	return false
+}
+
+// isTimeoutError 判斷錯誤是否為超時
+func isTimeoutError(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// providerChain 返回選定的提供者及備用提供者，無法建立的提供者會被跳過
+func providerChain(cfg config.Config, primary config.ModelRef) ([]Provider, error) {
+	refs := []config.ModelRef{primary}
+	for _, ref := range cfg.LLMFallbacks {
+		if ref != primary {
+			refs = append(refs, ref)
+		}
+	}
+
+	providers := []Provider{}
+	var firstErr error
+	for _, ref := range refs {
+		provider, err := NewProviderFor(cfg, ref)
+		if err != nil {
+			LogWarning("無法建立 LLM 提供者 %s (%s): %v", ref.Provider, ref.Model, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		providers = append(providers, provider)
+	}
+
+	if len(providers) == 0 {
+		return nil, firstErr
+	}
+	return providers, nil
+}
+
+// providerCall 使用單一提供者完成一次呼叫
+type providerCall func(ctx context.Context, provider Provider, prompt Prompt) (ProviderResult, error)
+
+// errPartialStream 包裝已經輸出部分內容的串流錯誤，此時不能再切換提供者
+type errPartialStream struct {
+	err error
+}
+
+// Error 實現 error 介面
+func (e *errPartialStream) Error() string {
+	return fmt.Sprintf("串流中斷: %v", e.err)
+}
+
+// Unwrap 返回底層錯誤，讓呼叫方可以辨識客戶端斷線和 APIError
+func (e *errPartialStream) Unwrap() error {
+	return e.err
+}
+
+// callWithFailover 依序嘗試提供者鏈，直到成功或遇到不觸發故障轉移的錯誤
+func callWithFailover(ctx context.Context, cfg config.Config, primary config.ModelRef, buildPrompt func(Provider) Prompt, call providerCall) (ProviderResult, error) {
+	providers, err := providerChain(cfg, primary)
+	if err != nil {
+		LogErrorDetails(err, "建立 LLM 提供者失敗")
+		return ProviderResult{}, err
+	}
+
+	policy := NewFailoverPolicy(cfg.LLMFailoverOn)
+
+	var lastErr error
+	for i, provider := range providers {
+		prompt := buildPrompt(provider)
+
+		attemptCtx, idle, cancel := withIdleTimeout(ctx, cfg.LLMTimeout)
+		result, err := call(attemptCtx, provider, prompt)
+		cancel()
+
+		var partial *errPartialStream
+		if err != nil && idle.expired() && !errors.As(err, &partial) {
+			err = fmt.Errorf("%w: %s 超過 %v 沒有回應", context.DeadlineExceeded, provider.Name(), cfg.LLMTimeout)
+		}
+
+		if err == nil {
+			result.Provider = provider.Name()
+			result.Model = prompt.Model
+			if result.Model == "" {
+				result.Model = provider.Model()
+			}
+			if i > 0 {
+				LogInfo("由備用提供者 %s (%s) 回答", result.Provider, result.Model)
+			}
+			return result, nil
+		}
+
+		lastErr = err
+		// 客戶端已取消、串流已輸出內容或錯誤不屬於故障轉移類別時直接返回
+		if ctx.Err() != nil || errors.As(err, &partial) || !policy.ShouldFailover(err) {
+			return ProviderResult{}, err
+		}
+		if i < len(providers)-1 {
+			LogWarning("提供者 %s 失敗，切換到下一個提供者: %v", provider.Name(), err)
+		}
+	}
+
+	return ProviderResult{}, lastErr
+}
+
+// idleTimeout 在超過時限沒有活動時取消請求
+// 串流每收到一個事件就重新計時，因此 LLM_TIMEOUT 限制的是首個事件前和事件之間的等待，
+// 而不是整個串流的長度；非串流請求沒有中間事件，仍以整個請求計時
+type idleTimeout struct {
+	timer   *time.Timer
+	timeout time.Duration
+	fired   int32
+}
+
+// idleTimeoutKey 在 context 中保存 idleTimeout 的鍵
+type idleTimeoutKey struct{}
+
+// withIdleTimeout 返回在 timeout 內沒有活動時會被取消的 context
+func withIdleTimeout(ctx context.Context, timeout time.Duration) (context.Context, *idleTimeout, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	idle := &idleTimeout{timeout: timeout}
+	idle.timer = time.AfterFunc(timeout, func() {
+		atomic.StoreInt32(&idle.fired, 1)
+		cancel()
+	})
+	return context.WithValue(ctx, idleTimeoutKey{}, idle), idle, func() {
+		idle.timer.Stop()
+		cancel()
+	}
+}
+
+// expired 判斷是否因超時而取消
+func (t *idleTimeout) expired() bool {
+	return atomic.LoadInt32(&t.fired) == 1
+}
+
+// touchIdleTimeout 記錄一次活動，重新開始計時
+func touchIdleTimeout(ctx context.Context) {
+	if idle, ok := ctx.Value(idleTimeoutKey{}).(*idleTimeout); ok && !idle.expired() {
+		idle.timer.Reset(idle.timeout)
+	}
+}
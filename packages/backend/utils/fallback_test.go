@@ -0,0 +1,168 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/rocker15962/llm-web-assistant/packages/backend/config"
+)
+
+// timeoutError 模擬網絡超時
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestFailoverPolicy(t *testing.T) {
+	policy := NewFailoverPolicy([]string{" 429 ", "5XX", "timeout", "404"})
+	network := NewFailoverPolicy([]string{FailoverNetwork, FailoverEmpty, FailoverClientError})
+
+	tests := []struct {
+		name   string
+		policy FailoverPolicy
+		err    error
+		want   bool
+	}{
+		{"rate limit", policy, &APIError{StatusCode: 429}, true},
+		{"server error", policy, &APIError{StatusCode: 503}, true},
+		{"specific status", policy, &APIError{StatusCode: 404}, true},
+		{"other client error", policy, &APIError{StatusCode: 400}, false},
+		{"wrapped api error", policy, fmt.Errorf("呼叫失敗: %w", &APIError{StatusCode: 500}), true},
+		{"deadline", policy, context.DeadlineExceeded, true},
+		{"net timeout", policy, timeoutError{}, true},
+		{"empty answer not listed", policy, ErrEmptyAnswer, false},
+		{"canceled", policy, context.Canceled, false},
+		{"client error class", network, &APIError{StatusCode: 401}, true},
+		{"server error not listed", network, &APIError{StatusCode: 500}, false},
+		{"empty answer", network, ErrEmptyAnswer, true},
+		{"network", network, &net.OpError{Op: "dial", Err: errors.New("connection refused")}, true},
+		{"timeout not listed", network, context.DeadlineExceeded, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.policy.ShouldFailover(tt.err); got != tt.want {
+			t.Errorf("%s: ShouldFailover(%v) = %v, want %v", tt.name, tt.err, got, tt.want)
+		}
+	}
+}
+
+// failoverConfig 返回以本地 ollama 模型為主、llamacpp 為備用的配置，兩者都不需要 API 金鑰
+func failoverConfig(rules ...string) config.Config {
+	return config.Config{
+		LLMProvider:   ProviderOllama,
+		LLMModel:      "primary",
+		LLMTimeout:    time.Second,
+		LLMFallbacks:  []config.ModelRef{{Provider: ProviderLlamaCpp, Model: "backup"}},
+		LLMFailoverOn: rules,
+	}
+}
+
+func TestCallWithFailover(t *testing.T) {
+	primary := config.ModelRef{Provider: ProviderOllama, Model: "primary"}
+	tests := []struct {
+		name         string
+		rules        []string
+		primaryErr   error
+		wantProvider string
+		wantCalls    int
+		wantErr      bool
+	}{
+		{"primary succeeds", []string{FailoverRateLimit}, nil, ProviderOllama, 1, false},
+		{"rate limited", []string{FailoverRateLimit}, &APIError{StatusCode: 429}, ProviderLlamaCpp, 2, false},
+		{"rule not listed", []string{FailoverRateLimit}, &APIError{StatusCode: 400}, "", 1, true},
+		{"partial stream", []string{FailoverServerError}, &errPartialStream{err: &APIError{StatusCode: 500}}, "", 1, true},
+	}
+
+	for _, tt := range tests {
+		calls := 0
+		call := func(ctx context.Context, provider Provider, prompt Prompt) (ProviderResult, error) {
+			calls++
+			if provider.Name() == ProviderOllama && tt.primaryErr != nil {
+				return ProviderResult{}, tt.primaryErr
+			}
+			return ProviderResult{Answer: "回答"}, nil
+		}
+
+		result, err := callWithFailover(context.Background(), failoverConfig(tt.rules...), primary, func(Provider) Prompt {
+			return Prompt{}
+		}, call)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+		if calls != tt.wantCalls {
+			t.Errorf("%s: calls = %d, want %d", tt.name, calls, tt.wantCalls)
+		}
+		if result.Provider != tt.wantProvider {
+			t.Errorf("%s: provider = %q, want %q", tt.name, result.Provider, tt.wantProvider)
+		}
+	}
+}
+
+func TestCallWithFailoverReportsModel(t *testing.T) {
+	cfg := failoverConfig(FailoverRateLimit)
+	call := func(ctx context.Context, provider Provider, prompt Prompt) (ProviderResult, error) {
+		return ProviderResult{Answer: "回答"}, nil
+	}
+
+	// 提示詞未指定模型時使用提供者的默認模型
+	result, err := callWithFailover(context.Background(), cfg, config.ModelRef{Provider: ProviderOllama, Model: "primary"}, func(Provider) Prompt {
+		return Prompt{}
+	}, call)
+	if err != nil {
+		t.Fatalf("callWithFailover: %v", err)
+	}
+	if result.Model != "primary" {
+		t.Errorf("model = %q, want primary", result.Model)
+	}
+}
+
+func TestCallWithFailoverIdleTimeout(t *testing.T) {
+	cfg := failoverConfig(FailoverTimeout)
+	cfg.LLMTimeout = 20 * time.Millisecond
+
+	// 主要提供者一直沒有回應，超時後切換到備用提供者
+	call := func(ctx context.Context, provider Provider, prompt Prompt) (ProviderResult, error) {
+		if provider.Name() == ProviderOllama {
+			<-ctx.Done()
+			return ProviderResult{}, ctx.Err()
+		}
+		return ProviderResult{Answer: "回答"}, nil
+	}
+
+	result, err := callWithFailover(context.Background(), cfg, config.ModelRef{Provider: ProviderOllama, Model: "primary"}, func(Provider) Prompt {
+		return Prompt{}
+	}, call)
+	if err != nil {
+		t.Fatalf("callWithFailover: %v", err)
+	}
+	if result.Provider != ProviderLlamaCpp {
+		t.Errorf("provider = %q, want %q", result.Provider, ProviderLlamaCpp)
+	}
+}
+
+func TestCallWithFailoverClientCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	calls := 0
+	call := func(ctx context.Context, provider Provider, prompt Prompt) (ProviderResult, error) {
+		calls++
+		return ProviderResult{}, ctx.Err()
+	}
+
+	// 客戶端已斷線時不嘗試備用提供者
+	_, err := callWithFailover(ctx, failoverConfig(FailoverNetwork, FailoverTimeout), config.ModelRef{Provider: ProviderOllama, Model: "primary"}, func(Provider) Prompt {
+		return Prompt{}
+	}, call)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1", calls)
+	}
+}
@@ -0,0 +1,18 @@
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// GenerateID 生成帶有前綴的隨機識別碼，例如 ws_3f9a...
+func GenerateID(prefix string) string {
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		// 隨機數來源不可用時退回使用時間戳
+		return fmt.Sprintf("%s_%x", prefix, time.Now().UnixNano())
+	}
+	return prefix + "_" + hex.EncodeToString(buf)
+}
@@ -1,46 +1,159 @@
 package utils
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
-	"io"
-	"net/http"
-	"os"
 	"strings"
 	"time"
 
+	"github.com/rocker15962/llm-web-assistant/packages/backend/config"
+	"github.com/rocker15962/llm-web-assistant/packages/backend/extract"
 	"github.com/rocker15962/llm-web-assistant/packages/backend/models"
 )
 
-// GenerateResponse 生成回應
-func GenerateResponse(req models.AskRequest) (models.AskResponse, error) {
+// GenerateResponse 生成回應，ctx 取消時（例如客戶端斷線）會中止所有上游請求
+func GenerateResponse(ctx context.Context, req models.AskRequest) (models.AskResponse, error) {
 	startTime := time.Now()
 
-	// 獲取 API 密鑰
-	apiKey := os.Getenv("LLM_API_KEY")
-	if apiKey == "" {
-		return models.AskResponse{}, fmt.Errorf("未設置 LLM_API_KEY 環境變數")
+	response, err := generate(ctx, req, callProvider)
+	if err != nil {
+		return models.AskResponse{}, err
+	}
+
+	// 記錄處理時間
+	processingTime := time.Since(startTime)
+	LogDebug("LLM 處理完成，耗時: %v", processingTime)
+
+	return response, nil
+}
+
+// generate 選擇模型、構建提示詞並依序呼叫提供者鏈
+func generate(ctx context.Context, req models.AskRequest, call providerCall) (models.AskResponse, error) {
+	cfg := config.LoadConfig()
+
+	// 選擇請求指定的模型
+	ref, err := ResolveModel(cfg, req.Model)
+	if err != nil {
+		return models.AskResponse{}, err
 	}
 
-	// 獲取 API 端點
-	apiEndpoint := os.Getenv("LLM_API_ENDPOINT")
-	if apiEndpoint == "" {
-		apiEndpoint = "https://api.openai.com/v1/responses" // 默認使用 OpenAI responses API
+	req = ExtractPageHTML(req)
+
+	// 啟用分段摘要且頁面超出上下文時，先並行摘錄各段內容
+	req, mapUsage, err := mapReducePage(ctx, cfg, ref, req)
+	if err != nil {
+		return models.AskResponse{}, err
+	}
+
+	// 歷史加上頁面內容會超出模型上下文時，將較早的輪次壓縮為摘要
+	compacted, summaryUsage, err := compactHistory(ctx, cfg, ref, req)
+	if err != nil {
+		return models.AskResponse{}, err
 	}
 
-	// 獲取模型名稱
-	modelName := os.Getenv("LLM_MODEL")
-	if modelName == "" {
-		modelName = "gpt-4o-mini" // 默認使用 GPT-4o-mini
+	result, err := callWithFailover(ctx, cfg, ref, func(provider Provider) Prompt {
+		return promptFor(ctx, compacted, provider)
+	}, withReplayFallback(compacted, withCalibration(call)))
+	if err != nil {
+		return models.AskResponse{}, err
+	}
+
+	// 返回結果
+	response := models.AskResponse{
+		Answer:         result.Answer,
+		Usage:          result.Usage,
+		Provider:       result.Provider,
+		Model:          result.Model,
+		ConversationID: req.ConversationID,
+		ResponseID:     result.ResponseID,
+		Truncated:      result.Truncated,
+	}
+	if result.Truncated {
+		LogWarning("回答不完整，可能已達到最大輸出 token 數")
 	}
+	addUsage(&response.Usage, mapUsage)
 
-	// 構建 API 請求
-	apiReq := map[string]interface{}{
-		"model": modelName,
+	// 本次產生了新的摘要時返回給客戶端，供後續請求重用
+	if compacted.SummarizedTurns != req.SummarizedTurns {
+		response.HistorySummary = compacted.HistorySummary
+		response.SummarizedTurns = compacted.SummarizedTurns
+		addUsage(&response.Usage, summaryUsage)
 	}
 
-	// 構建系統提示詞
+	return response, nil
+}
+
+// ExtractPageHTML 請求帶有原始 HTML 時提取正文，轉為 Markdown 放入頁面內容
+// 提取失敗或沒有正文時只使用客戶端提供的頁面內容；處理後清除 HTML，避免重複提取
+func ExtractPageHTML(req models.AskRequest) models.AskRequest {
+	if req.HTML == "" {
+		return req
+	}
+	html := req.HTML
+	req.HTML = ""
+
+	result, err := extract.Extract(html)
+	if err != nil {
+		LogWarning("提取頁面正文失敗，使用原有頁面內容: %v", err)
+		return req
+	}
+	if result.Markdown == "" {
+		LogWarning("頁面 HTML 中沒有可用的正文，使用原有頁面內容")
+		return req
+	}
+
+	LogDebug("已從 HTML 提取正文: %s -> %s", FormatBytes(len(html)), FormatBytes(len(result.Markdown)))
+	page := models.PageContent{Version: models.PageContentVersion}
+	if req.PageContent != nil {
+		page = *req.PageContent
+	}
+	page.Markdown = result.Markdown
+	req.PageContent = &page
+	if req.Title == "" {
+		req.Title = result.Title
+	}
+	return req
+}
+
+// TrimHistory 從最新的輪次開始保留，直到超過 token 預算
+// 壓縮歷史時用來決定逐字保留的輪次
+func TrimHistory(history []models.ConversationTurn, budget int) []models.ConversationTurn {
+	used := 0
+	start := len(history)
+	for i := len(history) - 1; i >= 0; i-- {
+		used += EstimateTokens(history[i].Question) + EstimateTokens(history[i].Answer)
+		if used > budget {
+			break
+		}
+		start = i
+	}
+
+	return history[start:]
+}
+
+// buildPrompt 根據問答請求構建提示詞，頁面上下文按提供者的 token 預算填入
+func buildPrompt(ctx context.Context, req models.AskRequest, provider Provider) Prompt {
+	system := buildSystemPrompt(req)
+	if req.HistorySummary != "" {
+		system += "\n\n先前對話的摘要：\n" + req.HistorySummary
+	}
+
+	budget := pageContextBudgetFor(provider)
+	if req.PageNotes != "" {
+		budget = reduceBudgetFor(provider)
+	}
+
+	return Prompt{
+		System:          system,
+		Messages:        append(historyMessages(req.History), buildUserMessage(ctx, req, budget)),
+		MaxOutputTokens: maxOutputTokens(req),
+		Temperature:     0.7,
+		UseWebSearch:    req.UseWebSearch,
+	}
+}
+
+// buildSystemPrompt 構建系統提示詞
+func buildSystemPrompt(req models.AskRequest) string {
 	systemPrompt := "你是一個專業的網頁分析助手。"
 
 	if req.IsSimple {
@@ -60,252 +173,136 @@ func GenerateResponse(req models.AskRequest) (models.AskResponse, error) {
 2. 回答用戶的問題，基於你從網頁中獲得的信息
 3. 如果無法從提供的資料中找到答案，請誠實說明`
 
-	// 構建輸入消息
-	input := []map[string]interface{}{}
+	rules := 3
+	if req.PageContent != nil && len(req.PageContent.Pages) > 0 {
+		rules++
+		systemPrompt += fmt.Sprintf(`
+%d. 內容來自 PDF 文件，每段開頭標有頁碼，例如 [第 3 頁]；回答時請在引用的內容後以（第 N 頁）標註出處`, rules)
+	}
+	if selected, _ := selectionOf(req); selected != "" {
+		rules++
+		systemPrompt += fmt.Sprintf(`
+%d. 用戶選取了網頁中的一段文字，問題中的「這個」「這段」「這句話」等指的就是選取的文字；
+請以選取的文字為回答的重點，其餘網頁內容只作為理解選取文字的背景`, rules)
+	}
 
-	// 添加系統消息
-	input = append(input, map[string]interface{}{
-		"role": "system",
-		"content": []map[string]interface{}{
-			{
-				"type": "input_text",
-				"text": systemPrompt,
-			},
-		},
-	})
+	return systemPrompt
+}
 
-	// 構建用戶消息
-	userContent := []map[string]interface{}{}
+// historyMessages 將先前的對話輪次轉換為提示詞消息
+func historyMessages(history []models.ConversationTurn) []PromptMessage {
+	messages := []PromptMessage{}
+	for _, turn := range history {
+		messages = append(messages,
+			PromptMessage{Role: "user", Text: turn.Question},
+			PromptMessage{Role: "assistant", Text: turn.Answer},
+		)
+	}
+	return messages
+}
 
+// buildUserMessage 構建包含問題、頁面內容和截圖的用戶消息
+func buildUserMessage(ctx context.Context, req models.AskRequest, budget int) PromptMessage {
 	// 添加文本內容
 	userPrompt := fmt.Sprintf("我正在瀏覽網頁：%s\n\n我的問題是：%s", req.Title, req.Question)
 
-	// 如果有頁面內容，添加到提示詞
-	if req.PageContent != "" {
-		userPrompt += "\n\n網頁內容摘要：\n"
-
-		// 嘗試解析 JSON 格式的頁面內容
-		var contentObj map[string]interface{}
-		if err := json.Unmarshal([]byte(req.PageContent), &contentObj); err == nil {
-			// 成功解析 JSON
-			if headings, ok := contentObj["headings"].([]interface{}); ok && len(headings) > 0 {
-				userPrompt += "標題：\n"
-				for i, h := range headings {
-					if i < 5 { // 限制標題數量
-						userPrompt += fmt.Sprintf("- %s\n", h)
-					} else {
-						userPrompt += "...(更多標題)\n"
-						break
-					}
-				}
-				userPrompt += "\n"
-			}
-
-			if paragraphs, ok := contentObj["paragraphs"].([]interface{}); ok && len(paragraphs) > 0 {
-				userPrompt += "內容摘要：\n"
-				for i, p := range paragraphs {
-					if i < 3 { // 限制段落數量
-						userPrompt += fmt.Sprintf("%s\n\n", p)
-					} else {
-						userPrompt += "...(更多內容)\n"
-						break
-					}
-				}
-			}
-		} else {
-			// 無法解析 JSON，直接使用文本
-			// 限制長度以避免 token 過多
-			const maxContentLength = 2000
-			if len(req.PageContent) > maxContentLength {
-				userPrompt += req.PageContent[:maxContentLength] + "...(內容已截斷)"
-			} else {
-				userPrompt += req.PageContent
-			}
+	// 選取的文字放在頁面內容之前，其餘頁面內容作為次要的背景
+	header, page, query := "網頁內容摘要：\n", req.PageContent, req.Question
+	if selected, selectionContext := selectionOf(req); selected != "" {
+		section, used := buildSelection(selected, selectionContext, budget)
+		userPrompt += "\n\n" + section
+		budget -= used
+
+		header = "其餘網頁內容（僅供參考）：\n"
+		// 以選取的文字輔助挑選相關段落，並避免重複列出選取文字
+		query += "\n" + selected
+		if page != nil {
+			rest := *page
+			rest.SelectedText = ""
+			page = &rest
 		}
 	}
 
-	// 添加文本內容到用戶輸入
-	userContent = append(userContent, map[string]interface{}{
-		"type": "input_text",
-		"text": userPrompt,
-	})
-
-	// 添加截圖（如果有）
-	if req.Screenshot != "" {
-		// 確保截圖格式正確
-		if !strings.HasPrefix(req.Screenshot, "data:image/") {
-			if strings.HasPrefix(req.Screenshot, "data:") {
-				req.Screenshot = strings.Replace(req.Screenshot, "data:", "data:image/jpeg;base64,", 1)
-			} else {
-				req.Screenshot = "data:image/jpeg;base64," + req.Screenshot
-			}
-		}
-
-		userContent = append(userContent, map[string]interface{}{
-			"type":      "input_image",
-			"image_url": req.Screenshot,
+	// 頁面已分段摘錄時直接使用各段摘錄，否則整理頁面內容
+	if req.PageNotes != "" {
+		notes, _ := TruncateToTokens(req.PageNotes, budget)
+		userPrompt += "\n\n" + header + notes
+	} else if !page.IsEmpty() {
+		userPrompt += "\n\n" + header
+		userPrompt += buildPageContext(ctx, ContextRequest{
+			Question:    query,
+			URL:         req.URL,
+			PageContent: page,
+			Budget:      budget,
 		})
 	}
 
-	// 添加用戶消息到輸入
-	input = append(input, map[string]interface{}{
-		"role":    "user",
-		"content": userContent,
-	})
-
-	// 設置輸入
-	apiReq["input"] = input
+	msg := PromptMessage{Role: "user", Text: userPrompt}
 
-	// 設置 max_tokens 根據簡單/詳細模式
-	if req.IsSimple {
-		apiReq["max_output_tokens"] = 500
-	} else {
-		apiReq["max_output_tokens"] = 2000
+	// 添加截圖（如果有）
+	if req.Screenshot != "" {
+		msg.Images = append(msg.Images, normalizeImageDataURL(req.Screenshot))
 	}
 
-	// 設置溫度
-	apiReq["temperature"] = 0.7
-
-	// 如果啟用了網絡搜索，添加工具
-	if req.UseWebSearch {
-		LogDebug("啟用網絡搜索功能")
-		apiReq["tools"] = []map[string]interface{}{
-			{
-				"type": "web_search_preview",
-			},
-		}
-	}
+	return msg
+}
 
-	// 序列化請求
-	reqBody, err := json.Marshal(apiReq)
-	if err != nil {
-		LogErrorDetails(err, "序列化 LLM API 請求失敗")
-		return models.AskResponse{}, err
+// selectionOf 返回使用者選取的文字和所在段落，請求未提供時使用頁面內容中的選取文字
+func selectionOf(req models.AskRequest) (string, string) {
+	selected := strings.TrimSpace(req.SelectedText)
+	if selected == "" && req.PageContent != nil {
+		selected = req.PageContent.SelectedText
 	}
-
-	// 記錄完整的請求內容（用於調試）
-	LogDebug("OpenAI 請求內容: %s", string(reqBody))
-
-	// 創建 HTTP 請求
-	httpReq, err := http.NewRequest("POST", apiEndpoint, bytes.NewBuffer(reqBody))
-	if err != nil {
-		LogErrorDetails(err, "創建 HTTP 請求失敗")
-		return models.AskResponse{}, err
+	if selected == "" {
+		return "", ""
 	}
 
-	// 設置請求頭
-	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("Authorization", "Bearer "+apiKey)
-
-	// 發送請求
-	LogDebug("發送請求到 LLM API: %s", apiEndpoint)
-	client := &http.Client{Timeout: 60 * time.Second}
-	resp, err := client.Do(httpReq)
-	if err != nil {
-		LogErrorDetails(err, "發送 LLM API 請求失敗")
-		return models.AskResponse{}, err
+	selectionContext := strings.TrimSpace(req.SelectionContext)
+	if selectionContext == selected {
+		selectionContext = ""
 	}
-	defer resp.Body.Close()
+	return selected, selectionContext
+}
 
-	// 讀取響應體
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		LogErrorDetails(err, "讀取 LLM API 響應失敗")
-		return models.AskResponse{}, err
+// buildSelection 構建選取文字的段落，返回使用的 token 數
+// 選取文字最多使用一半的預算，所在段落最多使用四分之一
+func buildSelection(selected, selectionContext string, budget int) (string, int) {
+	text, _ := TruncateToTokens(selected, budget/2)
+	section := "我選取的文字：\n" + text
+	if selectionContext != "" {
+		text, _ = TruncateToTokens(selectionContext, budget/4)
+		section += "\n\n選取文字所在的段落：\n" + text
 	}
+	return section, EstimateTokens(section)
+}
 
-	// 檢查響應狀態
-	if resp.StatusCode != http.StatusOK {
-		// 嘗試解析錯誤響應
-		var errorResp struct {
-			Error struct {
-				Message string `json:"message"`
-				Type    string `json:"type"`
-				Code    string `json:"code"`
-			} `json:"error"`
-		}
-
-		if err := json.Unmarshal(respBody, &errorResp); err == nil && errorResp.Error.Message != "" {
-			errorMsg := fmt.Sprintf("LLM API 錯誤: %s (類型: %s, 代碼: %s)",
-				errorResp.Error.Message, errorResp.Error.Type, errorResp.Error.Code)
-			LogError(errorMsg)
-			LogDebug("完整錯誤響應: %s", string(respBody))
-			return models.AskResponse{}, fmt.Errorf(errorMsg)
-		}
-
-		// 如果無法解析錯誤響應，則返回原始響應
-		errorMsg := fmt.Sprintf("LLM API 返回狀態碼: %d, 響應: %s", resp.StatusCode, string(respBody))
-		LogError(errorMsg)
-		return models.AskResponse{}, fmt.Errorf("LLM API 返回狀態碼: %d", resp.StatusCode)
+// maxOutputTokens 根據簡單/詳細模式返回最大輸出 token 數
+func maxOutputTokens(req models.AskRequest) int {
+	if req.IsSimple {
+		return 500
 	}
+	return 2000
+}
 
-	// 記錄完整的響應內容（用於調試）
-	LogDebug("OpenAI 響應內容: %s", string(respBody))
+// EstimateAskTokens 粗略估計一次問答的 token 用量，用於生成前預留 WebSocket 會話的配額
+// 頁面內容以頁面上下文預算計算，歷史最多計入 LLM_HISTORY_TOKEN_BUDGET，加上最大輸出 token 數
+func EstimateAskTokens(cfg config.Config, req models.AskRequest) int {
+	tokens := EstimateTokens(req.Question) + maxOutputTokens(req)
 
-	// 解析響應
-	var responseObj map[string]interface{}
-	if err := json.Unmarshal(respBody, &responseObj); err != nil {
-		LogErrorDetails(err, "解析 LLM API 響應失敗")
-		LogDebug("無法解析的響應內容: %s", string(respBody))
-		return models.AskResponse{}, err
-	}
-
-	// 提取回答文本
-	var answer string
-
-	// 從 output 數組中查找 message 類型的內容
-	if output, ok := responseObj["output"].([]interface{}); ok {
-		for _, item := range output {
-			if outputItem, ok := item.(map[string]interface{}); ok {
-				if outputType, ok := outputItem["type"].(string); ok && outputType == "message" {
-					if content, ok := outputItem["content"].([]interface{}); ok && len(content) > 0 {
-						for _, contentItem := range content {
-							if textItem, ok := contentItem.(map[string]interface{}); ok {
-								if textType, ok := textItem["type"].(string); ok && textType == "output_text" {
-									if text, ok := textItem["text"].(string); ok {
-										answer = text
-										break
-									}
-								}
-							}
-						}
-					}
-				}
-			}
+	if !req.PageContent.IsEmpty() {
+		budget := cfg.ContextBudget
+		if budget == 0 {
+			budget = config.DefaultContextBudget
 		}
+		tokens += budget
 	}
 
-	if answer == "" {
-		LogError("無法從 LLM API 響應中提取回答")
-		LogDebug("完整響應: %s", string(respBody))
-		return models.AskResponse{}, fmt.Errorf("無法從 LLM API 響應中提取回答")
+	history := 0
+	for _, turn := range req.History {
+		history += EstimateTokens(turn.Question) + EstimateTokens(turn.Answer)
 	}
-
-	// 提取 token 使用量
-	var promptTokens, completionTokens, totalTokens int
-	if usage, ok := responseObj["usage"].(map[string]interface{}); ok {
-		if pt, ok := usage["input_tokens"].(float64); ok {
-			promptTokens = int(pt)
-		}
-		if ct, ok := usage["output_tokens"].(float64); ok {
-			completionTokens = int(ct)
-		}
-		if tt, ok := usage["total_tokens"].(float64); ok {
-			totalTokens = int(tt)
-		}
+	if history > cfg.HistoryBudget {
+		history = cfg.HistoryBudget
 	}
-
-	// 記錄處理時間
-	processingTime := time.Since(startTime)
-	LogDebug("LLM 處理完成，耗時: %v", processingTime)
-
-	// 返回結果
-	return models.AskResponse{
-		Answer: answer,
-		Usage: models.TokenUsage{
-			PromptTokens:     promptTokens,
-			CompletionTokens: completionTokens,
-			TotalTokens:      totalTokens,
-		},
-	}, nil
+	return tokens + history
 }
@@ -0,0 +1,202 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/rocker15962/llm-web-assistant/packages/backend/config"
+	"github.com/rocker15962/llm-web-assistant/packages/backend/models"
+)
+
+const (
+	// mapChunkTokens 分段摘要時每段的 token 上限，另受模型上下文限制
+	mapChunkTokens = 6000
+	// maxMapChunks 最多分段數，避免超長文件產生大量呼叫
+	maxMapChunks = 64
+	// mapMaxOutputTokens 每段摘錄的最大輸出 token 數
+	mapMaxOutputTokens = 600
+	// noRelevantContent 分段中沒有相關內容時模型回覆的標記
+	noRelevantContent = "無相關內容"
+)
+
+// mapReducePage 在請求啟用分段摘要且頁面超出上下文預算時，
+// 將頁面切分為多段並行摘錄與問題相關的內容，合併後的摘錄放入 PageNotes
+// 返回改寫後的請求以及所有分段呼叫的 token 使用量
+func mapReducePage(ctx context.Context, cfg config.Config, ref config.ModelRef, req models.AskRequest) (models.AskRequest, models.TokenUsage, error) {
+	var usage models.TokenUsage
+	if !req.MapReduce || req.PageContent.IsEmpty() {
+		return req, usage, nil
+	}
+
+	provider, err := NewProviderFor(cfg, ref)
+	if err != nil {
+		return req, usage, err
+	}
+
+	// 串接上游回應時不會重送頁面內容
+	if chainsPreviousResponse(req, provider) {
+		return req, usage, nil
+	}
+
+	sections := splitPageContent(req.PageContent)
+	if fitsBudget(sections, pageContextBudgetFor(provider)) {
+		return req, usage, nil
+	}
+
+	chunkTokens := mapChunkTokens
+	if limit := ModelInfoFor(cfg, ref).ContextWindow / 2; chunkTokens > limit {
+		chunkTokens = limit
+	}
+	chunkTokens = int(float64(chunkTokens) / calibrationRatio(provider.Name(), provider.Model()))
+
+	chunks := chunkPassages(sections.passages, chunkTokens)
+	total := len(chunks)
+	if total > maxMapChunks {
+		LogWarning("頁面內容分為 %d 段，超過上限，只處理前 %d 段", total, maxMapChunks)
+		chunks = chunks[:maxMapChunks]
+	}
+
+	LogInfo("頁面內容超出上下文預算，分為 %d 段摘錄（並行 %d）", len(chunks), cfg.MapParallel)
+	notes, usage, err := mapChunks(ctx, cfg, ref, req, chunks)
+	if err != nil {
+		return req, usage, fmt.Errorf("分段摘錄頁面內容失敗: %w", err)
+	}
+
+	// 各段摘錄直接交給最後的回答，不再經過頁面上下文構建器截斷；
+	// 保留頁面內容讓提示詞繼續要求標註 PDF 頁碼並使用選取文字
+	req.PageNotes = combineNotes(notes, total)
+	return req, usage, nil
+}
+
+// reduceBudgetFor 返回合併摘錄可用的 token 預算
+// 使用模型上下文的一半，其餘留給系統提示詞、歷史和輸出，不受頁面上下文預算限制
+func reduceBudgetFor(provider Provider) int {
+	ref := config.ModelRef{Provider: provider.Name(), Model: provider.Model()}
+	budget := ModelInfoFor(config.LoadConfig(), ref).ContextWindow / 2
+	return int(float64(budget) / calibrationRatio(provider.Name(), provider.Model()))
+}
+
+// mapChunks 以有限的並行數對每段呼叫 LLM，任一段失敗時取消其餘呼叫
+// 返回按原順序排列的各段摘錄以及累計的 token 使用量
+func mapChunks(ctx context.Context, cfg config.Config, ref config.ModelRef, req models.AskRequest, chunks []string) ([]string, models.TokenUsage, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		usage    models.TokenUsage
+		firstErr error
+	)
+	notes := make([]string, len(chunks))
+	headings := chunkHeadings(chunks)
+	slots := make(chan struct{}, cfg.MapParallel)
+
+	for i, chunk := range chunks {
+		wg.Add(1)
+		go func(i int, chunk string) {
+			defer wg.Done()
+
+			select {
+			case slots <- struct{}{}:
+				defer func() { <-slots }()
+			case <-ctx.Done():
+				return
+			}
+
+			prompt := mapPrompt(req, chunk, headings[i], i, len(chunks))
+			result, err := callWithFailover(ctx, cfg, ref, func(Provider) Prompt {
+				return prompt
+			}, callProvider)
+
+			mu.Lock()
+			defer mu.Unlock()
+			addUsage(&usage, result.Usage)
+			if err != nil {
+				if firstErr == nil {
+					firstErr = fmt.Errorf("第 %d 段: %w", i+1, err)
+					cancel()
+				}
+				return
+			}
+			notes[i] = strings.TrimSpace(result.Answer)
+		}(i, chunk)
+	}
+	wg.Wait()
+
+	if firstErr == nil {
+		firstErr = ctx.Err()
+	}
+	return notes, usage, firstErr
+}
+
+// chunkHeadings 返回每段之前最近的 Markdown 標題，讓摘錄能標明出處的章節
+// 段落本身以標題開頭時返回空字串；沒有標題位置的頁面內容（例如段落列表）也返回空字串
+func chunkHeadings(chunks []string) []string {
+	headings := make([]string, len(chunks))
+	current := ""
+	for i, chunk := range chunks {
+		if !strings.HasPrefix(chunk, "#") {
+			headings[i] = current
+		}
+
+		// 程式碼區塊中以 # 開頭的行是註解，不是標題
+		inCode := false
+		for _, line := range strings.Split(chunk, "\n") {
+			switch {
+			case strings.HasPrefix(line, "```"):
+				inCode = !inCode
+			case !inCode && strings.HasPrefix(line, "#"):
+				current = strings.TrimSpace(strings.TrimLeft(line, "#"))
+			}
+		}
+	}
+	return headings
+}
+
+// mapPrompt 構建單段摘錄的提示詞，heading 為段落之前最近的標題
+func mapPrompt(req models.AskRequest, chunk, heading string, index, total int) Prompt {
+	question := req.Question
+	if selected, _ := selectionOf(req); selected != "" {
+		// 問題通常指向選取的文字，摘錄時需要知道選取了什麼
+		selected, _ = TruncateToTokens(selected, mapMaxOutputTokens)
+		question += "\n（使用者選取的文字：" + selected + "）"
+	}
+	position := fmt.Sprintf("第 %d/%d 段", index+1, total)
+	if heading != "" {
+		position += "（位於章節「" + heading + "」之下）"
+	}
+	text := fmt.Sprintf("網頁：%s\n\n問題：%s\n\n以下是網頁內容的%s：\n%s", req.Title, question, position, chunk)
+	return Prompt{
+		System: `你負責閱讀長文件的其中一段，為之後的回答整理資料。
+請從這一段中摘錄與問題相關的事實、數據、條款和原文引述，並保留出處的標題、編號或頁碼。
+如果問題是要求摘要或概述，請摘要這一段的重點。
+只根據這一段的內容回答，不要推測其他段落。
+如果這一段沒有任何相關內容，只回覆「` + noRelevantContent + `」。`,
+		Messages:        []PromptMessage{{Role: "user", Text: text}},
+		MaxOutputTokens: mapMaxOutputTokens,
+		Temperature:     0.3,
+	}
+}
+
+// combineNotes 將各段摘錄按順序合併，略過沒有相關內容的段落
+// total 為頁面的總段數，超過上限而沒有摘錄的段落會在最後註明，讓回答說明內容不完整
+func combineNotes(notes []string, total int) string {
+	var b strings.Builder
+	for i, note := range notes {
+		if note == "" || strings.HasPrefix(note, noRelevantContent) {
+			continue
+		}
+		fmt.Fprintf(&b, "第 %d/%d 段的摘錄：\n%s\n\n", i+1, total, note)
+	}
+	if b.Len() == 0 {
+		b.WriteString("已閱讀的各段都沒有與問題相關的內容。\n\n")
+	}
+	if total > len(notes) {
+		fmt.Fprintf(&b, "注意：頁面共 %d 段，只閱讀了前 %d 段，第 %d 段之後的內容沒有被閱讀，回答時請向使用者說明這一點。",
+			total, len(notes), len(notes)+1)
+	}
+	return strings.TrimSpace(b.String())
+}
@@ -0,0 +1,156 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rocker15962/llm-web-assistant/packages/backend/config"
+	"github.com/rocker15962/llm-web-assistant/packages/backend/models"
+)
+
+func TestChunkHeadings(t *testing.T) {
+	chunks := []string{
+		"前言",
+		"# 第一章\n內容",
+		"續前章\n```\n# 程式碼註解\n```",
+		"## 第二節\n內容",
+		"更多內容",
+	}
+	want := []string{"", "", "第一章", "", "第二節"}
+
+	if got := chunkHeadings(chunks); !reflect.DeepEqual(got, want) {
+		t.Errorf("chunkHeadings = %q, want %q", got, want)
+	}
+}
+
+func TestCombineNotes(t *testing.T) {
+	tests := []struct {
+		name  string
+		notes []string
+		total int
+		want  []string
+		not   []string
+	}{
+		{
+			name:  "skips irrelevant chunks",
+			notes: []string{"甲", noRelevantContent + "。", "丙"},
+			total: 3,
+			want:  []string{"第 1/3 段的摘錄：\n甲", "第 3/3 段的摘錄：\n丙"},
+			not:   []string{"第 2/3 段", "注意"},
+		},
+		{
+			name:  "nothing relevant",
+			notes: []string{noRelevantContent},
+			total: 1,
+			want:  []string{"已閱讀的各段都沒有與問題相關的內容。"},
+		},
+		{
+			name:  "chunks over the limit",
+			notes: []string{"甲", "乙"},
+			total: 5,
+			want:  []string{"第 2/5 段的摘錄：\n乙", "頁面共 5 段，只閱讀了前 2 段，第 3 段之後"},
+		},
+	}
+
+	for _, tt := range tests {
+		got := combineNotes(tt.notes, tt.total)
+		for _, want := range tt.want {
+			if !strings.Contains(got, want) {
+				t.Errorf("%s: %q does not contain %q", tt.name, got, want)
+			}
+		}
+		for _, not := range tt.not {
+			if strings.Contains(got, not) {
+				t.Errorf("%s: %q should not contain %q", tt.name, got, not)
+			}
+		}
+	}
+}
+
+func TestMapPromptHeading(t *testing.T) {
+	req := models.AskRequest{Title: "文件", Question: "問題"}
+
+	prompt := mapPrompt(req, "內容", "第一章", 1, 4)
+	if text := prompt.Messages[0].Text; !strings.Contains(text, "第 2/4 段（位於章節「第一章」之下）") {
+		t.Errorf("prompt = %q, want the position and heading", text)
+	}
+	prompt = mapPrompt(req, "內容", "", 0, 4)
+	if text := prompt.Messages[0].Text; strings.Contains(text, "章節") {
+		t.Errorf("prompt = %q, want no heading", text)
+	}
+}
+
+func TestMapReducePage(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		var req ollamaRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+
+		// 第一段沒有相關內容，其餘段落返回摘錄
+		answer := "摘錄"
+		if strings.Contains(req.Messages[len(req.Messages)-1].Content, "p00 ") {
+			answer = noRelevantContent
+		}
+		fmt.Fprintf(w, `{"message":{"role":"assistant","content":%q},"prompt_eval_count":10,"eval_count":5}`, answer)
+	}))
+	defer server.Close()
+
+	ref := config.ModelRef{Provider: ProviderOllama, Model: "mapreduce-test"}
+	cfg := config.Config{
+		LLMProvider:    ref.Provider,
+		LLMModel:       ref.Model,
+		LLMApiEndpoint: server.URL,
+		LLMTimeout:     5 * time.Second,
+		MapParallel:    2,
+	}
+
+	paragraphs := make([]string, 12)
+	for i := range paragraphs {
+		paragraphs[i] = fmt.Sprintf("p%02d %s", i, strings.Repeat("x", 2000))
+	}
+	req := models.AskRequest{
+		Question:    "重點是什麼？",
+		MapReduce:   true,
+		PageContent: &models.PageContent{Paragraphs: paragraphs},
+	}
+
+	mapped, usage, err := mapReducePage(context.Background(), cfg, ref, req)
+	if err != nil {
+		t.Fatalf("mapReducePage: %v", err)
+	}
+
+	n := int(atomic.LoadInt32(&calls))
+	if n < 2 {
+		t.Fatalf("map calls = %d, want the page split into several chunks", n)
+	}
+	if usage.TotalTokens != 15*n {
+		t.Errorf("usage = %+v, want %d total tokens", usage, 15*n)
+	}
+	if strings.Contains(mapped.PageNotes, fmt.Sprintf("第 1/%d 段", n)) {
+		t.Errorf("PageNotes = %q, want the irrelevant first chunk skipped", mapped.PageNotes)
+	}
+	if !strings.Contains(mapped.PageNotes, fmt.Sprintf("第 %d/%d 段的摘錄：\n摘錄", n, n)) {
+		t.Errorf("PageNotes = %q, want the last chunk's notes", mapped.PageNotes)
+	}
+	if mapped.PageContent == nil {
+		t.Error("PageContent was dropped")
+	}
+
+	// 未啟用分段摘要時不呼叫 LLM
+	req.MapReduce = false
+	if unchanged, _, err := mapReducePage(context.Background(), cfg, ref, req); err != nil || unchanged.PageNotes != "" {
+		t.Errorf("map reduce disabled: PageNotes = %q, err = %v", unchanged.PageNotes, err)
+	}
+	if got := int(atomic.LoadInt32(&calls)); got != n {
+		t.Errorf("calls = %d after disabled request, want %d", got, n)
+	}
+}
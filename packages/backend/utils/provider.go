@@ -0,0 +1,294 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/rocker15962/llm-web-assistant/packages/backend/config"
+	"github.com/rocker15962/llm-web-assistant/packages/backend/models"
+)
+
+// 提供者名稱常量
+const (
+	ProviderOpenAI     = "openai"
+	ProviderOpenAIChat = "openai-chat"
+	ProviderAnthropic  = "anthropic"
+	ProviderOllama     = "ollama"
+	ProviderLlamaCpp   = "llamacpp"
+	ProviderGemini     = "gemini"
+	ProviderAzure      = "azure"
+)
+
+// llmHTTPClient 所有提供者共用的 HTTP 客戶端
+// 超時由每次呼叫的 context 控制（LLM_TIMEOUT，見 withIdleTimeout）
+var llmHTTPClient = &http.Client{}
+
+// PromptMessage 表示與提供者無關的對話消息
+type PromptMessage struct {
+	Role   string
+	Text   string
+	Images []string // data:image/...;base64 格式的圖片
+}
+
+// Prompt 表示與提供者無關的完整提示詞
+type Prompt struct {
+	Model           string
+	System          string
+	Messages        []PromptMessage
+	MaxOutputTokens int
+	Temperature     float64
+	UseWebSearch    bool
+	// PreviousResponseID 串接上游先前的回應，只有支援的提供者會使用
+	PreviousResponseID string
+}
+
+// ProviderResult 表示提供者解析後的回答
+type ProviderResult struct {
+	Answer   string
+	Usage    models.TokenUsage
+	Provider string // 實際回答的提供者
+	Model    string // 實際使用的模型
+	// ResponseID 上游回應的 ID，可用於串接後續問題
+	ResponseID string
+	// Truncated 回答因達到最大輸出 token 數等限制而不完整
+	Truncated bool
+}
+
+// ErrEmptyAnswer 表示提供者返回成功但沒有回答內容
+var ErrEmptyAnswer = errors.New("無法從 LLM API 響應中提取回答")
+
+// Provider 定義了 LLM 後端需要實現的介面
+type Provider interface {
+	// Name 返回提供者名稱
+	Name() string
+	// Model 返回提示詞未指定模型時使用的默認模型
+	Model() string
+	// BuildRequest 將提示詞轉換為提供者的 HTTP 請求
+	BuildRequest(ctx context.Context, prompt Prompt) (*http.Request, error)
+	// ParseResponse 從響應體中解析回答與 token 使用量
+	ParseResponse(body []byte) (ProviderResult, error)
+}
+
+// ProviderConfig 表示建立提供者所需的設定
+type ProviderConfig struct {
+	APIKey   string
+	Endpoint string
+	Model    string
+	Azure    config.AzureOpenAIConfig
+	Primary  bool // 是否為主要提供者，主要提供者也會讀取 LLM_API_KEY
+}
+
+// APIError 表示 LLM API 返回的錯誤
+type APIError struct {
+	Provider   string
+	StatusCode int
+	Message    string
+	Type       string
+	Code       string
+}
+
+// Error 實現 error 介面
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("LLM API 返回狀態碼: %d", e.StatusCode)
+	}
+	return fmt.Sprintf("LLM API 錯誤: %s (類型: %s, 代碼: %s)", e.Message, e.Type, e.Code)
+}
+
+// NewProvider 根據配置建立主要 LLM 提供者
+func NewProvider(cfg config.Config) (Provider, error) {
+	return NewProviderFor(cfg, config.ModelRef{Provider: cfg.LLMProvider, Model: cfg.LLMModel})
+}
+
+// NewProviderFor 建立指定提供者與模型的 LLM 提供者
+func NewProviderFor(cfg config.Config, ref config.ModelRef) (Provider, error) {
+	apiKey, endpoint := cfg.ProviderCredentials(ref.Provider)
+	pc := ProviderConfig{
+		APIKey:   apiKey,
+		Endpoint: endpoint,
+		Model:    ref.Model,
+		Azure:    cfg.AzureOpenAI,
+		Primary:  ref.Provider == cfg.LLMProvider,
+	}
+
+	switch strings.ToLower(ref.Provider) {
+	case ProviderOpenAI, "":
+		return NewOpenAIResponsesProvider(pc)
+	case ProviderOpenAIChat:
+		return NewOpenAIChatProvider(pc)
+	case ProviderAnthropic:
+		return NewAnthropicProvider(pc)
+	case ProviderOllama:
+		return NewOllamaProvider(pc)
+	case ProviderLlamaCpp:
+		return NewLlamaCppProvider(pc)
+	case ProviderGemini:
+		return NewGeminiProvider(pc)
+	case ProviderAzure:
+		return NewAzureOpenAIProvider(pc)
+	default:
+		return nil, fmt.Errorf("不支援的 LLM 提供者: %s", ref.Provider)
+	}
+}
+
+// errMissingAPIKey 返回缺少 API 金鑰的錯誤，列出提供者實際讀取的環境變數
+// 主要提供者也會讀取 LLM_API_KEY
+func errMissingAPIKey(provider string, cfg ProviderConfig) error {
+	env := config.ProviderKeyEnv(provider)
+	if cfg.Primary {
+		return fmt.Errorf("未設置 %s 或 %s 環境變數", env, config.EnvLLMApiKey)
+	}
+	return fmt.Errorf("未設置 %s 環境變數", env)
+}
+
+// callProvider 使用提供者發送請求並解析響應
+func callProvider(ctx context.Context, provider Provider, prompt Prompt) (ProviderResult, error) {
+	httpReq, err := provider.BuildRequest(ctx, prompt)
+	if err != nil {
+		LogErrorDetails(err, "創建 HTTP 請求失敗")
+		return ProviderResult{}, err
+	}
+
+	resp, err := sendProviderRequest(provider, httpReq)
+	if err != nil {
+		return ProviderResult{}, err
+	}
+	defer resp.Body.Close()
+
+	// 讀取響應體
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		LogErrorDetails(err, "讀取 LLM API 響應失敗")
+		return ProviderResult{}, err
+	}
+
+	// 記錄完整的響應內容（用於調試）
+	LogDebug("%s 響應內容: %s", provider.Name(), string(respBody))
+
+	result, err := provider.ParseResponse(respBody)
+	if err != nil {
+		LogErrorDetails(err, "解析 LLM API 響應失敗")
+		LogDebug("無法解析的響應內容: %s", string(respBody))
+		return ProviderResult{}, err
+	}
+
+	if result.Answer == "" {
+		LogError("無法從 LLM API 響應中提取回答")
+		LogDebug("完整響應: %s", string(respBody))
+		return ProviderResult{}, ErrEmptyAnswer
+	}
+
+	return result, nil
+}
+
+// sendProviderRequest 發送請求，非 200 狀態碼時讀取響應並返回 APIError
+// 成功時由呼叫者負責關閉響應體
+func sendProviderRequest(provider Provider, httpReq *http.Request) (*http.Response, error) {
+	LogDebug("發送請求到 LLM API (%s): %s", provider.Name(), httpReq.URL.Redacted())
+	resp, err := llmHTTPClient.Do(httpReq)
+	if err != nil {
+		LogErrorDetails(err, "發送 LLM API 請求失敗")
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusOK {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	// 檢查響應狀態
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		LogErrorDetails(err, "讀取 LLM API 響應失敗")
+		return nil, err
+	}
+
+	apiErr := parseAPIError(provider.Name(), resp.StatusCode, respBody)
+	if apiErr.Message != "" {
+		LogError(apiErr.Error())
+		LogDebug("完整錯誤響應: %s", string(respBody))
+	} else {
+		LogError("LLM API 返回狀態碼: %d, 響應: %s", resp.StatusCode, string(respBody))
+	}
+	return nil, apiErr
+}
+
+// parseAPIError 嘗試從錯誤響應中解析錯誤信息
+func parseAPIError(provider string, statusCode int, body []byte) *APIError {
+	apiErr := &APIError{Provider: provider, StatusCode: statusCode}
+
+	var errorResp struct {
+		Error struct {
+			Message string          `json:"message"`
+			Type    string          `json:"type"`
+			Status  string          `json:"status"`
+			Code    json.RawMessage `json:"code"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &errorResp); err != nil {
+		return apiErr
+	}
+
+	apiErr.Message = errorResp.Error.Message
+	apiErr.Type = errorResp.Error.Type
+	if apiErr.Type == "" {
+		apiErr.Type = errorResp.Error.Status
+	}
+	// 部分提供者的錯誤代碼是字串，部分是數字
+	apiErr.Code = strings.Trim(string(errorResp.Error.Code), `"`)
+	if apiErr.Code == "null" {
+		apiErr.Code = ""
+	}
+	return apiErr
+}
+
+// postJSON 創建帶有 JSON 請求體的 POST 請求
+func postJSON(ctx context.Context, url string, body interface{}) (*http.Request, error) {
+	reqBody, err := json.Marshal(body)
+	if err != nil {
+		LogErrorDetails(err, "序列化 LLM API 請求失敗")
+		return nil, err
+	}
+
+	// 記錄完整的請求內容（用於調試）
+	LogDebug("LLM 請求內容: %s", string(reqBody))
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	return httpReq, nil
+}
+
+// normalizeImageDataURL 確保截圖是 data:image/...;base64 格式
+func normalizeImageDataURL(image string) string {
+	if strings.HasPrefix(image, "data:image/") {
+		return image
+	}
+	if strings.HasPrefix(image, "data:") {
+		return strings.Replace(image, "data:", "data:image/jpeg;base64,", 1)
+	}
+	return "data:image/jpeg;base64," + image
+}
+
+// splitImageDataURL 將 data URL 拆分為媒體類型和 base64 數據
+func splitImageDataURL(dataURL string) (mediaType, data string) {
+	dataURL = normalizeImageDataURL(dataURL)
+	header, data, found := strings.Cut(dataURL, ",")
+	if !found {
+		return "image/jpeg", dataURL
+	}
+	mediaType = strings.TrimPrefix(header, "data:")
+	mediaType = strings.TrimSuffix(mediaType, ";base64")
+	if mediaType == "" {
+		mediaType = "image/jpeg"
+	}
+	return mediaType, data
+}
@@ -0,0 +1,168 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/rocker15962/llm-web-assistant/packages/backend/models"
+)
+
+// Anthropic Messages API 相關常量
+const (
+	defaultAnthropicEndpoint = "https://api.anthropic.com/v1/messages"
+	anthropicAPIVersion      = "2023-06-01"
+)
+
+// anthropicImageSource 表示 Anthropic 的 base64 圖片來源
+type anthropicImageSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type"`
+	Data      string `json:"data"`
+}
+
+// anthropicContent 表示 Anthropic 消息的內容區塊
+type anthropicContent struct {
+	Type   string                `json:"type"`
+	Text   string                `json:"text,omitempty"`
+	Source *anthropicImageSource `json:"source,omitempty"`
+}
+
+// anthropicMessage 表示 Anthropic 消息
+type anthropicMessage struct {
+	Role    string             `json:"role"`
+	Content []anthropicContent `json:"content"`
+}
+
+// anthropicTool 表示 Anthropic 的伺服器端工具
+type anthropicTool struct {
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	MaxUses int    `json:"max_uses,omitempty"`
+}
+
+// anthropicRequest 表示 Anthropic Messages API 請求
+type anthropicRequest struct {
+	Model       string             `json:"model"`
+	System      string             `json:"system,omitempty"`
+	Messages    []anthropicMessage `json:"messages"`
+	MaxTokens   int                `json:"max_tokens"`
+	Temperature float64            `json:"temperature"`
+	Tools       []anthropicTool    `json:"tools,omitempty"`
+}
+
+// anthropicResponse 表示 Anthropic Messages API 響應
+type anthropicResponse struct {
+	ID      string `json:"id"`
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	Usage struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+	StopReason string `json:"stop_reason"` // max_tokens 表示達到最大輸出 token 數
+}
+
+// AnthropicProvider 使用 Anthropic Messages API
+type AnthropicProvider struct {
+	cfg ProviderConfig
+}
+
+// NewAnthropicProvider 建立 Anthropic 提供者
+func NewAnthropicProvider(cfg ProviderConfig) (*AnthropicProvider, error) {
+	if cfg.APIKey == "" {
+		return nil, errMissingAPIKey(ProviderAnthropic, cfg)
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = defaultAnthropicEndpoint
+	}
+	return &AnthropicProvider{cfg: cfg}, nil
+}
+
+// Name 返回提供者名稱
+func (p *AnthropicProvider) Name() string {
+	return ProviderAnthropic
+}
+
+// Model 返回默認模型
+func (p *AnthropicProvider) Model() string {
+	return p.cfg.Model
+}
+
+// BuildRequest 構建 Anthropic Messages API 請求
+func (p *AnthropicProvider) BuildRequest(ctx context.Context, prompt Prompt) (*http.Request, error) {
+	model := prompt.Model
+	if model == "" {
+		model = p.cfg.Model
+	}
+
+	messages := []anthropicMessage{}
+	for _, msg := range prompt.Messages {
+		content := []anthropicContent{}
+		// Anthropic 建議圖片放在文字之前
+		for _, image := range msg.Images {
+			mediaType, data := splitImageDataURL(image)
+			content = append(content, anthropicContent{
+				Type: "image",
+				Source: &anthropicImageSource{
+					Type:      "base64",
+					MediaType: mediaType,
+					Data:      data,
+				},
+			})
+		}
+		content = append(content, anthropicContent{Type: "text", Text: msg.Text})
+		messages = append(messages, anthropicMessage{Role: msg.Role, Content: content})
+	}
+
+	apiReq := anthropicRequest{
+		Model:       model,
+		System:      prompt.System,
+		Messages:    messages,
+		MaxTokens:   prompt.MaxOutputTokens,
+		Temperature: prompt.Temperature,
+	}
+
+	// 如果啟用了網絡搜索，使用 Anthropic 的伺服器端搜索工具
+	if prompt.UseWebSearch {
+		LogDebug("啟用網絡搜索功能")
+		apiReq.Tools = []anthropicTool{{Type: "web_search_20250305", Name: "web_search", MaxUses: 5}}
+	}
+
+	httpReq, err := postJSON(ctx, p.cfg.Endpoint, apiReq)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("x-api-key", p.cfg.APIKey)
+	httpReq.Header.Set("anthropic-version", anthropicAPIVersion)
+	return httpReq, nil
+}
+
+// ParseResponse 解析 Anthropic Messages API 響應
+func (p *AnthropicProvider) ParseResponse(body []byte) (ProviderResult, error) {
+	var resp anthropicResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return ProviderResult{}, err
+	}
+
+	// 使用網絡搜索時，文字會被工具結果分成多個區塊
+	var answer strings.Builder
+	for _, block := range resp.Content {
+		if block.Type == "text" {
+			answer.WriteString(block.Text)
+		}
+	}
+
+	return ProviderResult{
+		Answer:    answer.String(),
+		Truncated: resp.StopReason == "max_tokens",
+		Usage: models.TokenUsage{
+			PromptTokens:     resp.Usage.InputTokens,
+			CompletionTokens: resp.Usage.OutputTokens,
+			TotalTokens:      resp.Usage.InputTokens + resp.Usage.OutputTokens,
+		},
+	}, nil
+}
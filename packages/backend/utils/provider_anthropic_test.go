@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"context"
+	"testing"
+)
+
+func TestAnthropicRoundTrip(t *testing.T) {
+	// 使用網絡搜索時回答被工具結果分成多個文字區塊
+	server, captured := newCaptureServer(t, `{
+		"id": "msg_1",
+		"content": [
+			{"type": "text", "text": "根據搜索，"},
+			{"type": "server_tool_use", "id": "srvtoolu_1"},
+			{"type": "web_search_tool_result", "tool_use_id": "srvtoolu_1"},
+			{"type": "text", "text": "回答"}
+		],
+		"usage": {"input_tokens": 10, "output_tokens": 5},
+		"stop_reason": "end_turn"
+	}`)
+
+	provider, err := NewAnthropicProvider(ProviderConfig{APIKey: "sk-ant-test", Endpoint: server.URL + "/v1/messages", Model: "claude-test"})
+	if err != nil {
+		t.Fatalf("NewAnthropicProvider: %v", err)
+	}
+
+	result, err := callProvider(context.Background(), provider, testPrompt())
+	if err != nil {
+		t.Fatalf("callProvider: %v", err)
+	}
+
+	want := ProviderResult{Answer: "根據搜索，回答", Usage: usage(10, 5, 15)}
+	if result != want {
+		t.Errorf("result = %+v, want %+v", result, want)
+	}
+
+	if captured.Path != "/v1/messages" {
+		t.Errorf("path = %q, want /v1/messages", captured.Path)
+	}
+	if got := captured.Header.Get("x-api-key"); got != "sk-ant-test" {
+		t.Errorf("x-api-key = %q", got)
+	}
+	if got := captured.Header.Get("anthropic-version"); got != anthropicAPIVersion {
+		t.Errorf("anthropic-version = %q, want %q", got, anthropicAPIVersion)
+	}
+	// 系統提示詞是頂層欄位，圖片放在文字之前
+	checkJSON(t, captured.Body, []jsonField{
+		{[]interface{}{"model"}, "claude-test"},
+		{[]interface{}{"system"}, "系統提示"},
+		{[]interface{}{"messages", 0, "role"}, "user"},
+		{[]interface{}{"messages", 0, "content", 0, "text"}, "第一題"},
+		{[]interface{}{"messages", 1, "role"}, "assistant"},
+		{[]interface{}{"messages", 2, "content", 0, "type"}, "image"},
+		{[]interface{}{"messages", 2, "content", 0, "source", "type"}, "base64"},
+		{[]interface{}{"messages", 2, "content", 0, "source", "media_type"}, "image/png"},
+		{[]interface{}{"messages", 2, "content", 0, "source", "data"}, "AAAA"},
+		{[]interface{}{"messages", 2, "content", 1, "text"}, "第二題"},
+		{[]interface{}{"max_tokens"}, float64(100)},
+		{[]interface{}{"temperature"}, 0.7},
+		{[]interface{}{"tools", 0, "type"}, "web_search_20250305"},
+		{[]interface{}{"tools", 0, "name"}, "web_search"},
+	})
+}
+
+func TestNewAnthropicProviderMissingKey(t *testing.T) {
+	if _, err := NewAnthropicProvider(ProviderConfig{}); err == nil {
+		t.Error("expected an error without an API key")
+	}
+}
@@ -0,0 +1,121 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Azure OpenAI 的 API 形式與默認 api-version
+const (
+	azureShapeResponses = "responses"
+	azureShapeChat      = "chat"
+
+	defaultAzureChatAPIVersion      = "2024-10-21"
+	defaultAzureResponsesAPIVersion = "2025-04-01-preview"
+)
+
+// AzureOpenAIProvider 使用 Azure OpenAI 部署，支援 responses 和 chat/completions 兩種形式
+type AzureOpenAIProvider struct {
+	cfg ProviderConfig
+}
+
+// NewAzureOpenAIProvider 建立 Azure OpenAI 提供者
+func NewAzureOpenAIProvider(cfg ProviderConfig) (*AzureOpenAIProvider, error) {
+	if cfg.APIKey == "" {
+		return nil, errMissingAPIKey(ProviderAzure, cfg)
+	}
+
+	// 資源端點優先使用 AZURE_OPENAI_ENDPOINT
+	if cfg.Azure.Endpoint == "" {
+		cfg.Azure.Endpoint = cfg.Endpoint
+	}
+	if cfg.Azure.Endpoint == "" {
+		return nil, fmt.Errorf("未設置 AZURE_OPENAI_ENDPOINT 環境變數")
+	}
+	cfg.Azure.Endpoint = strings.TrimSuffix(cfg.Azure.Endpoint, "/")
+
+	// 請求選擇的模型即部署名稱，未指定時才使用配置的默認部署
+	if cfg.Model != "" {
+		cfg.Azure.Deployment = cfg.Model
+	}
+	if cfg.Azure.Deployment == "" {
+		return nil, fmt.Errorf("未設置 AZURE_OPENAI_DEPLOYMENT 環境變數")
+	}
+
+	switch cfg.Azure.APIShape {
+	case azureShapeChat, "":
+		cfg.Azure.APIShape = azureShapeChat
+		if cfg.Azure.APIVersion == "" {
+			cfg.Azure.APIVersion = defaultAzureChatAPIVersion
+		}
+	case azureShapeResponses:
+		if cfg.Azure.APIVersion == "" {
+			cfg.Azure.APIVersion = defaultAzureResponsesAPIVersion
+		}
+	default:
+		return nil, fmt.Errorf("不支援的 Azure OpenAI API 形式: %s", cfg.Azure.APIShape)
+	}
+
+	return &AzureOpenAIProvider{cfg: cfg}, nil
+}
+
+// Name 返回提供者名稱
+func (p *AzureOpenAIProvider) Name() string {
+	return ProviderAzure
+}
+
+// Model 返回部署名稱
+func (p *AzureOpenAIProvider) Model() string {
+	return p.cfg.Azure.Deployment
+}
+
+// SupportsPreviousResponse 只有 responses 形式支援 previous_response_id
+func (p *AzureOpenAIProvider) SupportsPreviousResponse() bool {
+	return p.cfg.Azure.APIShape == azureShapeResponses
+}
+
+// BuildRequest 構建 Azure OpenAI 請求
+func (p *AzureOpenAIProvider) BuildRequest(ctx context.Context, prompt Prompt) (*http.Request, error) {
+	// Azure 以部署名稱選擇模型
+	deployment := p.cfg.Azure.Deployment
+	if prompt.Model != "" {
+		deployment = prompt.Model
+	}
+	prompt.Model = deployment
+
+	var (
+		endpoint string
+		body     interface{}
+	)
+	query := url.Values{"api-version": {p.cfg.Azure.APIVersion}}
+
+	if p.cfg.Azure.APIShape == azureShapeResponses {
+		endpoint = p.cfg.Azure.Endpoint + "/openai/responses?" + query.Encode()
+		body = buildResponsesRequest(prompt, deployment)
+	} else {
+		if prompt.UseWebSearch {
+			LogWarning("Azure OpenAI chat/completions 不支援 web_search_preview，已忽略網絡搜索")
+		}
+		endpoint = fmt.Sprintf("%s/openai/deployments/%s/chat/completions?%s",
+			p.cfg.Azure.Endpoint, url.PathEscape(deployment), query.Encode())
+		body = buildChatRequest(prompt, deployment)
+	}
+
+	httpReq, err := postJSON(ctx, endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("api-key", p.cfg.APIKey)
+	return httpReq, nil
+}
+
+// ParseResponse 根據 API 形式解析響應
+func (p *AzureOpenAIProvider) ParseResponse(body []byte) (ProviderResult, error) {
+	if p.cfg.Azure.APIShape == azureShapeResponses {
+		return parseResponsesResponse(body)
+	}
+	return parseChatResponse(body)
+}
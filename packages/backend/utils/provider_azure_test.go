@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rocker15962/llm-web-assistant/packages/backend/config"
+)
+
+func TestAzureChatRoundTrip(t *testing.T) {
+	server, captured := newCaptureServer(t, chatCompletionResponse)
+
+	provider, err := NewAzureOpenAIProvider(ProviderConfig{
+		APIKey: "azure-key",
+		Azure:  config.AzureOpenAIConfig{Endpoint: server.URL + "/", Deployment: "my-gpt"},
+	})
+	if err != nil {
+		t.Fatalf("NewAzureOpenAIProvider: %v", err)
+	}
+
+	result, err := callProvider(context.Background(), provider, testPrompt())
+	if err != nil {
+		t.Fatalf("callProvider: %v", err)
+	}
+
+	want := ProviderResult{Answer: "回答", Usage: usage(10, 5, 15)}
+	if result != want {
+		t.Errorf("result = %+v, want %+v", result, want)
+	}
+
+	if captured.Path != "/openai/deployments/my-gpt/chat/completions" {
+		t.Errorf("path = %q, want the deployment's chat/completions URL", captured.Path)
+	}
+	if got := captured.Query.Get("api-version"); got != defaultAzureChatAPIVersion {
+		t.Errorf("api-version = %q, want %q", got, defaultAzureChatAPIVersion)
+	}
+	if got := captured.Header.Get("api-key"); got != "azure-key" {
+		t.Errorf("api-key = %q", got)
+	}
+	if got := captured.Header.Get("Authorization"); got != "" {
+		t.Errorf("Authorization = %q, want none", got)
+	}
+	checkJSON(t, captured.Body, []jsonField{
+		{[]interface{}{"model"}, "my-gpt"},
+		{[]interface{}{"messages", 0, "content"}, "系統提示"},
+		{[]interface{}{"tools"}, nil},
+	})
+}
+
+func TestAzureResponsesRoundTrip(t *testing.T) {
+	server, captured := newCaptureServer(t, `{
+		"id": "resp_1",
+		"status": "completed",
+		"output": [{"type": "message", "content": [{"type": "output_text", "text": "回答"}]}],
+		"usage": {"input_tokens": 10, "output_tokens": 5, "total_tokens": 15}
+	}`)
+
+	// 請求選擇的模型即部署名稱，優先於默認部署
+	provider, err := NewAzureOpenAIProvider(ProviderConfig{
+		APIKey: "azure-key",
+		Model:  "other-gpt",
+		Azure: config.AzureOpenAIConfig{
+			Endpoint:   server.URL,
+			Deployment: "my-gpt",
+			APIShape:   azureShapeResponses,
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewAzureOpenAIProvider: %v", err)
+	}
+	if !provider.SupportsPreviousResponse() {
+		t.Error("responses shape should support previous_response_id")
+	}
+
+	result, err := callProvider(context.Background(), provider, testPrompt())
+	if err != nil {
+		t.Fatalf("callProvider: %v", err)
+	}
+
+	want := ProviderResult{Answer: "回答", ResponseID: "resp_1", Usage: usage(10, 5, 15)}
+	if result != want {
+		t.Errorf("result = %+v, want %+v", result, want)
+	}
+
+	if captured.Path != "/openai/responses" {
+		t.Errorf("path = %q, want /openai/responses", captured.Path)
+	}
+	if got := captured.Query.Get("api-version"); got != defaultAzureResponsesAPIVersion {
+		t.Errorf("api-version = %q, want %q", got, defaultAzureResponsesAPIVersion)
+	}
+	checkJSON(t, captured.Body, []jsonField{
+		{[]interface{}{"model"}, "other-gpt"},
+		{[]interface{}{"input", 3, "content", 1, "type"}, "input_image"},
+		{[]interface{}{"tools", 0, "type"}, "web_search_preview"},
+	})
+}
+
+func TestNewAzureOpenAIProviderErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  ProviderConfig
+	}{
+		{"missing key", ProviderConfig{Azure: config.AzureOpenAIConfig{Endpoint: "https://x.openai.azure.com", Deployment: "d"}}},
+		{"missing endpoint", ProviderConfig{APIKey: "k", Azure: config.AzureOpenAIConfig{Deployment: "d"}}},
+		{"missing deployment", ProviderConfig{APIKey: "k", Azure: config.AzureOpenAIConfig{Endpoint: "https://x.openai.azure.com"}}},
+		{"unknown shape", ProviderConfig{APIKey: "k", Azure: config.AzureOpenAIConfig{Endpoint: "https://x.openai.azure.com", Deployment: "d", APIShape: "completions"}}},
+	}
+
+	for _, tt := range tests {
+		if _, err := NewAzureOpenAIProvider(tt.cfg); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/rocker15962/llm-web-assistant/packages/backend/models"
+)
+
+// defaultGeminiEndpoint Gemini API 的模型端點前綴
+const defaultGeminiEndpoint = "https://generativelanguage.googleapis.com/v1beta/models"
+
+// geminiPageContextBudget Gemini 的頁面上下文 token 預算
+const geminiPageContextBudget = 32000
+
+// geminiInlineData 表示 Gemini 的內嵌二進位數據
+type geminiInlineData struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"`
+}
+
+// geminiPart 表示 Gemini 內容片段
+type geminiPart struct {
+	Text       string            `json:"text,omitempty"`
+	InlineData *geminiInlineData `json:"inlineData,omitempty"`
+}
+
+// geminiContent 表示 Gemini 的一則內容
+type geminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []geminiPart `json:"parts"`
+}
+
+// geminiGenerationConfig 表示 Gemini 的生成參數
+type geminiGenerationConfig struct {
+	Temperature     float64 `json:"temperature"`
+	MaxOutputTokens int     `json:"maxOutputTokens,omitempty"`
+}
+
+// geminiTool 表示 Gemini 的工具
+type geminiTool struct {
+	GoogleSearch *struct{} `json:"googleSearch,omitempty"`
+}
+
+// geminiRequest 表示 generateContent 請求
+type geminiRequest struct {
+	SystemInstruction *geminiContent         `json:"systemInstruction,omitempty"`
+	Contents          []geminiContent        `json:"contents"`
+	GenerationConfig  geminiGenerationConfig `json:"generationConfig"`
+	Tools             []geminiTool           `json:"tools,omitempty"`
+}
+
+// geminiResponse 表示 generateContent 響應
+type geminiResponse struct {
+	ResponseID string `json:"responseId"`
+	Candidates []struct {
+		Content struct {
+			Parts []struct {
+				Text string `json:"text"`
+			} `json:"parts"`
+		} `json:"content"`
+		FinishReason string `json:"finishReason"`
+	} `json:"candidates"`
+	UsageMetadata struct {
+		PromptTokenCount     int `json:"promptTokenCount"`
+		CandidatesTokenCount int `json:"candidatesTokenCount"`
+		TotalTokenCount      int `json:"totalTokenCount"`
+	} `json:"usageMetadata"`
+}
+
+// GeminiProvider 使用 Google Gemini generateContent API
+type GeminiProvider struct {
+	cfg ProviderConfig
+}
+
+// NewGeminiProvider 建立 Gemini 提供者
+func NewGeminiProvider(cfg ProviderConfig) (*GeminiProvider, error) {
+	if cfg.APIKey == "" {
+		return nil, errMissingAPIKey(ProviderGemini, cfg)
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = defaultGeminiEndpoint
+	}
+	return &GeminiProvider{cfg: cfg}, nil
+}
+
+// Name 返回提供者名稱
+func (p *GeminiProvider) Name() string {
+	return ProviderGemini
+}
+
+// Model 返回默認模型
+func (p *GeminiProvider) Model() string {
+	return p.cfg.Model
+}
+
+// PageContextBudget 利用 Gemini 的長上下文放入更多頁面內容
+func (p *GeminiProvider) PageContextBudget() int {
+	return geminiPageContextBudget
+}
+
+// BuildRequest 構建 generateContent 請求
+func (p *GeminiProvider) BuildRequest(ctx context.Context, prompt Prompt) (*http.Request, error) {
+	model := prompt.Model
+	if model == "" {
+		model = p.cfg.Model
+	}
+
+	contents := []geminiContent{}
+	for _, msg := range prompt.Messages {
+		// Gemini 使用 model 代表助手角色
+		role := msg.Role
+		if role == "assistant" {
+			role = "model"
+		}
+
+		parts := []geminiPart{{Text: msg.Text}}
+		for _, image := range msg.Images {
+			mimeType, data := splitImageDataURL(image)
+			parts = append(parts, geminiPart{
+				InlineData: &geminiInlineData{MimeType: mimeType, Data: data},
+			})
+		}
+		contents = append(contents, geminiContent{Role: role, Parts: parts})
+	}
+
+	apiReq := geminiRequest{
+		Contents: contents,
+		GenerationConfig: geminiGenerationConfig{
+			Temperature:     prompt.Temperature,
+			MaxOutputTokens: prompt.MaxOutputTokens,
+		},
+	}
+	if prompt.System != "" {
+		apiReq.SystemInstruction = &geminiContent{Parts: []geminiPart{{Text: prompt.System}}}
+	}
+
+	// 如果啟用了網絡搜索，使用 Google 搜索工具
+	if prompt.UseWebSearch {
+		LogDebug("啟用網絡搜索功能")
+		apiReq.Tools = []geminiTool{{GoogleSearch: &struct{}{}}}
+	}
+
+	httpReq, err := postJSON(ctx, geminiURL(p.cfg.Endpoint, model), apiReq)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("x-goog-api-key", p.cfg.APIKey)
+	return httpReq, nil
+}
+
+// ParseResponse 解析 generateContent 響應
+func (p *GeminiProvider) ParseResponse(body []byte) (ProviderResult, error) {
+	var resp geminiResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return ProviderResult{}, err
+	}
+
+	var answer strings.Builder
+	var truncated bool
+	if len(resp.Candidates) > 0 {
+		truncated = resp.Candidates[0].FinishReason == "MAX_TOKENS"
+		for _, part := range resp.Candidates[0].Content.Parts {
+			answer.WriteString(part.Text)
+		}
+		if answer.Len() == 0 {
+			LogWarning("Gemini 未返回內容，結束原因: %s", resp.Candidates[0].FinishReason)
+		}
+	}
+
+	return ProviderResult{
+		Answer:    answer.String(),
+		Truncated: truncated,
+		Usage: models.TokenUsage{
+			PromptTokens:     resp.UsageMetadata.PromptTokenCount,
+			CompletionTokens: resp.UsageMetadata.CandidatesTokenCount,
+			TotalTokens:      resp.UsageMetadata.TotalTokenCount,
+		},
+	}, nil
+}
+
+// geminiURL 根據端點和模型組合 generateContent URL
+func geminiURL(endpoint, model string) string {
+	// 允許直接設置完整的 generateContent URL
+	if strings.Contains(endpoint, ":generateContent") {
+		return endpoint
+	}
+	return strings.TrimSuffix(endpoint, "/") + "/" + model + ":generateContent"
+}
@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"context"
+	"testing"
+)
+
+func TestGeminiRoundTrip(t *testing.T) {
+	server, captured := newCaptureServer(t, `{
+		"responseId": "gemini-1",
+		"candidates": [{
+			"content": {"role": "model", "parts": [{"text": "根據搜索，"}, {"text": "回答"}]},
+			"finishReason": "STOP"
+		}],
+		"usageMetadata": {"promptTokenCount": 10, "candidatesTokenCount": 5, "totalTokenCount": 15}
+	}`)
+
+	provider, err := NewGeminiProvider(ProviderConfig{APIKey: "gemini-key", Endpoint: server.URL + "/v1beta/models/", Model: "gemini-test"})
+	if err != nil {
+		t.Fatalf("NewGeminiProvider: %v", err)
+	}
+
+	result, err := callProvider(context.Background(), provider, testPrompt())
+	if err != nil {
+		t.Fatalf("callProvider: %v", err)
+	}
+
+	want := ProviderResult{Answer: "根據搜索，回答", Usage: usage(10, 5, 15)}
+	if result != want {
+		t.Errorf("result = %+v, want %+v", result, want)
+	}
+
+	if captured.Path != "/v1beta/models/gemini-test:generateContent" {
+		t.Errorf("path = %q, want the model's generateContent URL", captured.Path)
+	}
+	// API 金鑰放在標頭中，不出現在 URL 的查詢參數
+	if got := captured.Header.Get("x-goog-api-key"); got != "gemini-key" {
+		t.Errorf("x-goog-api-key = %q", got)
+	}
+	if len(captured.Query) != 0 {
+		t.Errorf("query = %v, want none", captured.Query)
+	}
+	// 助手角色為 model，圖片以 inlineData 放在文字之後
+	checkJSON(t, captured.Body, []jsonField{
+		{[]interface{}{"systemInstruction", "parts", 0, "text"}, "系統提示"},
+		{[]interface{}{"contents", 0, "role"}, "user"},
+		{[]interface{}{"contents", 0, "parts", 0, "text"}, "第一題"},
+		{[]interface{}{"contents", 1, "role"}, "model"},
+		{[]interface{}{"contents", 2, "parts", 0, "text"}, "第二題"},
+		{[]interface{}{"contents", 2, "parts", 1, "inlineData", "mimeType"}, "image/png"},
+		{[]interface{}{"contents", 2, "parts", 1, "inlineData", "data"}, "AAAA"},
+		{[]interface{}{"generationConfig", "maxOutputTokens"}, float64(100)},
+		{[]interface{}{"generationConfig", "temperature"}, 0.7},
+		{[]interface{}{"tools", 0, "googleSearch"}, map[string]interface{}{}},
+	})
+}
+
+func TestGeminiURL(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		want     string
+	}{
+		{defaultGeminiEndpoint, defaultGeminiEndpoint + "/gemini-test:generateContent"},
+		{defaultGeminiEndpoint + "/", defaultGeminiEndpoint + "/gemini-test:generateContent"},
+		{"https://proxy.example/models/custom:generateContent", "https://proxy.example/models/custom:generateContent"},
+	}
+
+	for _, tt := range tests {
+		if got := geminiURL(tt.endpoint, "gemini-test"); got != tt.want {
+			t.Errorf("geminiURL(%q) = %q, want %q", tt.endpoint, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/rocker15962/llm-web-assistant/packages/backend/models"
+)
+
+// 本地模型伺服器的默認端點
+const (
+	defaultOllamaEndpoint = "http://localhost:11434/api/chat"
+	// llama.cpp 的 llama-server 默認使用 8080 端口，與本服務衝突，
+	// 因此假設以 --port 8081 啟動
+	defaultLlamaCppEndpoint = "http://localhost:8081/v1/chat/completions"
+)
+
+// offlineSystemNote 在不支援網絡搜索時附加到系統提示詞
+const offlineSystemNote = `
+注意：目前無法使用網絡搜索，請僅根據提供的網頁內容和截圖回答。`
+
+// ollamaMessage 表示 Ollama 原生 API 的消息
+type ollamaMessage struct {
+	Role    string   `json:"role"`
+	Content string   `json:"content"`
+	Images  []string `json:"images,omitempty"` // 不含 data: 前綴的 base64
+}
+
+// ollamaOptions 表示 Ollama 的模型參數
+type ollamaOptions struct {
+	Temperature float64 `json:"temperature"`
+	NumPredict  int     `json:"num_predict,omitempty"`
+}
+
+// ollamaRequest 表示 Ollama /api/chat 請求
+type ollamaRequest struct {
+	Model    string          `json:"model"`
+	Messages []ollamaMessage `json:"messages"`
+	Stream   bool            `json:"stream"`
+	Options  ollamaOptions   `json:"options"`
+}
+
+// ollamaResponse 表示 Ollama /api/chat 非串流響應
+type ollamaResponse struct {
+	Message struct {
+		Role    string `json:"role"`
+		Content string `json:"content"`
+	} `json:"message"`
+	PromptEvalCount int `json:"prompt_eval_count"`
+	EvalCount       int `json:"eval_count"`
+
+	// DoneReason 為 length 時表示達到 num_predict 上限
+	DoneReason string `json:"done_reason"`
+}
+
+// OllamaProvider 使用 Ollama 原生 /api/chat API
+type OllamaProvider struct {
+	cfg ProviderConfig
+}
+
+// NewOllamaProvider 建立 Ollama 提供者，本地伺服器不需要 API 密鑰
+func NewOllamaProvider(cfg ProviderConfig) (*OllamaProvider, error) {
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = defaultOllamaEndpoint
+	}
+	return &OllamaProvider{cfg: cfg}, nil
+}
+
+// Name 返回提供者名稱
+func (p *OllamaProvider) Name() string {
+	return ProviderOllama
+}
+
+// Model 返回默認模型
+func (p *OllamaProvider) Model() string {
+	return p.cfg.Model
+}
+
+// BuildRequest 構建 Ollama /api/chat 請求
+func (p *OllamaProvider) BuildRequest(ctx context.Context, prompt Prompt) (*http.Request, error) {
+	prompt = withoutWebSearch(prompt)
+
+	model := prompt.Model
+	if model == "" {
+		model = p.cfg.Model
+	}
+
+	messages := []ollamaMessage{}
+	if prompt.System != "" {
+		messages = append(messages, ollamaMessage{Role: "system", Content: prompt.System})
+	}
+	for _, msg := range prompt.Messages {
+		ollamaMsg := ollamaMessage{Role: msg.Role, Content: msg.Text}
+		for _, image := range msg.Images {
+			_, data := splitImageDataURL(image)
+			ollamaMsg.Images = append(ollamaMsg.Images, data)
+		}
+		messages = append(messages, ollamaMsg)
+	}
+
+	httpReq, err := postJSON(ctx, p.cfg.Endpoint, ollamaRequest{
+		Model:    model,
+		Messages: messages,
+		Stream:   false,
+		Options: ollamaOptions{
+			Temperature: prompt.Temperature,
+			NumPredict:  prompt.MaxOutputTokens,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if p.cfg.APIKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+p.cfg.APIKey)
+	}
+	return httpReq, nil
+}
+
+// ParseResponse 解析 Ollama /api/chat 響應
+func (p *OllamaProvider) ParseResponse(body []byte) (ProviderResult, error) {
+	var resp ollamaResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return ProviderResult{}, err
+	}
+
+	return ProviderResult{
+		Answer:    resp.Message.Content,
+		Truncated: resp.DoneReason == "length",
+		Usage: models.TokenUsage{
+			PromptTokens:     resp.PromptEvalCount,
+			CompletionTokens: resp.EvalCount,
+			TotalTokens:      resp.PromptEvalCount + resp.EvalCount,
+		},
+	}, nil
+}
+
+// LlamaCppProvider 使用 llama.cpp 伺服器的 OpenAI 相容 chat/completions API
+type LlamaCppProvider struct {
+	cfg ProviderConfig
+}
+
+// NewLlamaCppProvider 建立 llama.cpp 提供者，本地伺服器不需要 API 密鑰
+func NewLlamaCppProvider(cfg ProviderConfig) (*LlamaCppProvider, error) {
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = defaultLlamaCppEndpoint
+	}
+	return &LlamaCppProvider{cfg: cfg}, nil
+}
+
+// Name 返回提供者名稱
+func (p *LlamaCppProvider) Name() string {
+	return ProviderLlamaCpp
+}
+
+// Model 返回默認模型
+func (p *LlamaCppProvider) Model() string {
+	return p.cfg.Model
+}
+
+// BuildRequest 構建 OpenAI 相容的 chat/completions 請求
+func (p *LlamaCppProvider) BuildRequest(ctx context.Context, prompt Prompt) (*http.Request, error) {
+	httpReq, err := postJSON(ctx, p.cfg.Endpoint, buildChatRequest(withoutWebSearch(prompt), p.cfg.Model))
+	if err != nil {
+		return nil, err
+	}
+	if p.cfg.APIKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+p.cfg.APIKey)
+	}
+	return httpReq, nil
+}
+
+// ParseResponse 解析 chat/completions 響應
+func (p *LlamaCppProvider) ParseResponse(body []byte) (ProviderResult, error) {
+	return parseChatResponse(body)
+}
+
+// withoutWebSearch 移除網絡搜索並提示模型只根據提供的資料回答
+func withoutWebSearch(prompt Prompt) Prompt {
+	if !prompt.UseWebSearch {
+		return prompt
+	}
+	LogWarning("本地模型不支援網絡搜索，已忽略 web_search_preview")
+	prompt.UseWebSearch = false
+	prompt.System += offlineSystemNote
+	return prompt
+}
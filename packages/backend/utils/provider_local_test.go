@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestOllamaRoundTrip(t *testing.T) {
+	server, captured := newCaptureServer(t, `{
+		"model": "llama-test",
+		"message": {"role": "assistant", "content": "回答"},
+		"done": true,
+		"done_reason": "stop",
+		"prompt_eval_count": 10,
+		"eval_count": 5
+	}`)
+
+	provider, err := NewOllamaProvider(ProviderConfig{Endpoint: server.URL + "/api/chat", Model: "llama-test"})
+	if err != nil {
+		t.Fatalf("NewOllamaProvider: %v", err)
+	}
+
+	result, err := callProvider(context.Background(), provider, testPrompt())
+	if err != nil {
+		t.Fatalf("callProvider: %v", err)
+	}
+
+	want := ProviderResult{Answer: "回答", Usage: usage(10, 5, 15)}
+	if result != want {
+		t.Errorf("result = %+v, want %+v", result, want)
+	}
+
+	if captured.Path != "/api/chat" {
+		t.Errorf("path = %q, want /api/chat", captured.Path)
+	}
+	// 本地伺服器不需要 API 金鑰
+	if got := captured.Header.Get("Authorization"); got != "" {
+		t.Errorf("Authorization = %q, want none", got)
+	}
+	// 圖片不含 data: 前綴，網絡搜索改為提示模型只根據提供的資料回答
+	checkJSON(t, captured.Body, []jsonField{
+		{[]interface{}{"model"}, "llama-test"},
+		{[]interface{}{"stream"}, false},
+		{[]interface{}{"messages", 0, "role"}, "system"},
+		{[]interface{}{"messages", 1, "content"}, "第一題"},
+		{[]interface{}{"messages", 2, "role"}, "assistant"},
+		{[]interface{}{"messages", 3, "content"}, "第二題"},
+		{[]interface{}{"messages", 3, "images", 0}, "AAAA"},
+		{[]interface{}{"options", "num_predict"}, float64(100)},
+		{[]interface{}{"options", "temperature"}, 0.7},
+	})
+	if system, _ := jsonPath(captured.Body, "messages", 0, "content").(string); !strings.HasSuffix(system, offlineSystemNote) {
+		t.Errorf("system prompt = %q, want the offline note", system)
+	}
+}
+
+func TestLlamaCppRoundTrip(t *testing.T) {
+	server, captured := newCaptureServer(t, chatCompletionResponse)
+
+	provider, err := NewLlamaCppProvider(ProviderConfig{APIKey: "local-key", Endpoint: server.URL + "/v1/chat/completions", Model: "llama-test"})
+	if err != nil {
+		t.Fatalf("NewLlamaCppProvider: %v", err)
+	}
+
+	result, err := callProvider(context.Background(), provider, testPrompt())
+	if err != nil {
+		t.Fatalf("callProvider: %v", err)
+	}
+
+	want := ProviderResult{Answer: "回答", Usage: usage(10, 5, 15)}
+	if result != want {
+		t.Errorf("result = %+v, want %+v", result, want)
+	}
+
+	// 設置了 API 金鑰時仍然發送
+	if got := captured.Header.Get("Authorization"); got != "Bearer local-key" {
+		t.Errorf("Authorization = %q", got)
+	}
+	checkJSON(t, captured.Body, []jsonField{
+		{[]interface{}{"model"}, "llama-test"},
+		{[]interface{}{"messages", 3, "content", 1, "image_url", "url"}, "data:image/png;base64,AAAA"},
+		{[]interface{}{"tools"}, nil},
+	})
+	if system, _ := jsonPath(captured.Body, "messages", 0, "content").(string); !strings.HasSuffix(system, offlineSystemNote) {
+		t.Errorf("system prompt = %q, want the offline note", system)
+	}
+}
@@ -0,0 +1,269 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/rocker15962/llm-web-assistant/packages/backend/models"
+)
+
+// defaultOpenAIResponsesEndpoint OpenAI Responses API 的默認端點
+const defaultOpenAIResponsesEndpoint = "https://api.openai.com/v1/responses"
+
+// responsesContent 表示 Responses API 的輸入內容
+type responsesContent struct {
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	ImageURL string `json:"image_url,omitempty"`
+}
+
+// responsesInput 表示 Responses API 的輸入消息
+type responsesInput struct {
+	Role    string             `json:"role"`
+	Content []responsesContent `json:"content"`
+}
+
+// responsesTool 表示 Responses API 的工具
+type responsesTool struct {
+	Type string `json:"type"`
+}
+
+// responsesRequest 表示 Responses API 請求
+type responsesRequest struct {
+	Model           string           `json:"model"`
+	Input           []responsesInput `json:"input"`
+	MaxOutputTokens int              `json:"max_output_tokens,omitempty"`
+	Temperature     float64          `json:"temperature"`
+	Tools           []responsesTool  `json:"tools,omitempty"`
+	Stream          bool             `json:"stream,omitempty"`
+
+	PreviousResponseID string `json:"previous_response_id,omitempty"`
+}
+
+// responsesResponse 表示 Responses API 響應
+type responsesResponse struct {
+	ID     string `json:"id"`
+	Status string `json:"status"` // completed、incomplete 等
+	Output []struct {
+		Type    string `json:"type"`
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+	} `json:"output"`
+	Usage struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+		TotalTokens  int `json:"total_tokens"`
+	} `json:"usage"`
+}
+
+// OpenAIResponsesProvider 使用 OpenAI Responses API (/v1/responses)
+type OpenAIResponsesProvider struct {
+	cfg ProviderConfig
+}
+
+// NewOpenAIResponsesProvider 建立 OpenAI Responses API 提供者
+func NewOpenAIResponsesProvider(cfg ProviderConfig) (*OpenAIResponsesProvider, error) {
+	if cfg.APIKey == "" {
+		return nil, errMissingAPIKey(ProviderOpenAI, cfg)
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = defaultOpenAIResponsesEndpoint
+	}
+	return &OpenAIResponsesProvider{cfg: cfg}, nil
+}
+
+// Name 返回提供者名稱
+func (p *OpenAIResponsesProvider) Name() string {
+	return ProviderOpenAI
+}
+
+// Model 返回默認模型
+func (p *OpenAIResponsesProvider) Model() string {
+	return p.cfg.Model
+}
+
+// BuildRequest 構建 Responses API 請求
+func (p *OpenAIResponsesProvider) BuildRequest(ctx context.Context, prompt Prompt) (*http.Request, error) {
+	httpReq, err := postJSON(ctx, p.cfg.Endpoint, buildResponsesRequest(prompt, p.cfg.Model))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+p.cfg.APIKey)
+	return httpReq, nil
+}
+
+// ParseResponse 解析 Responses API 響應
+func (p *OpenAIResponsesProvider) ParseResponse(body []byte) (ProviderResult, error) {
+	return parseResponsesResponse(body)
+}
+
+// SupportsPreviousResponse Responses API 支援 previous_response_id
+func (p *OpenAIResponsesProvider) SupportsPreviousResponse() bool {
+	return true
+}
+
+// BuildStreamRequest 構建 stream: true 的 Responses API 請求
+func (p *OpenAIResponsesProvider) BuildStreamRequest(ctx context.Context, prompt Prompt) (*http.Request, error) {
+	apiReq := buildResponsesRequest(prompt, p.cfg.Model)
+	apiReq.Stream = true
+
+	httpReq, err := postJSON(ctx, p.cfg.Endpoint, apiReq)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+p.cfg.APIKey)
+	httpReq.Header.Set("Accept", "text/event-stream")
+	return httpReq, nil
+}
+
+// ParseStreamEvent 解析 Responses API 的串流事件
+func (p *OpenAIResponsesProvider) ParseStreamEvent(event string, data []byte) (StreamEvent, bool, error) {
+	return parseResponsesStreamEvent(p.Name(), event, data)
+}
+
+// buildResponsesRequest 將提示詞轉換為 Responses API 請求體
+func buildResponsesRequest(prompt Prompt, defaultModel string) responsesRequest {
+	model := prompt.Model
+	if model == "" {
+		model = defaultModel
+	}
+
+	input := []responsesInput{}
+
+	// 添加系統消息
+	if prompt.System != "" {
+		input = append(input, responsesInput{
+			Role:    "system",
+			Content: []responsesContent{{Type: "input_text", Text: prompt.System}},
+		})
+	}
+
+	for _, msg := range prompt.Messages {
+		// 助手的歷史回答使用 output_text，其餘使用 input_text
+		textType := "input_text"
+		if msg.Role == "assistant" {
+			textType = "output_text"
+		}
+
+		content := []responsesContent{{Type: textType, Text: msg.Text}}
+		for _, image := range msg.Images {
+			content = append(content, responsesContent{Type: "input_image", ImageURL: image})
+		}
+		input = append(input, responsesInput{Role: msg.Role, Content: content})
+	}
+
+	apiReq := responsesRequest{
+		Model:           model,
+		Input:           input,
+		MaxOutputTokens: prompt.MaxOutputTokens,
+		Temperature:     prompt.Temperature,
+
+		PreviousResponseID: prompt.PreviousResponseID,
+	}
+
+	// 如果啟用了網絡搜索，添加工具
+	if prompt.UseWebSearch {
+		LogDebug("啟用網絡搜索功能")
+		apiReq.Tools = []responsesTool{{Type: "web_search_preview"}}
+	}
+
+	return apiReq
+}
+
+// parseResponsesResponse 從 Responses API 響應中提取回答和 token 使用量
+func parseResponsesResponse(body []byte) (ProviderResult, error) {
+	var resp responsesResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return ProviderResult{}, err
+	}
+
+	// 從 output 數組中查找 message 類型的內容
+	var answer string
+	for _, item := range resp.Output {
+		if item.Type != "message" {
+			continue
+		}
+		for _, content := range item.Content {
+			if content.Type == "output_text" {
+				answer = content.Text
+				break
+			}
+		}
+	}
+
+	return ProviderResult{
+		Answer:     answer,
+		ResponseID: resp.ID,
+		Usage: models.TokenUsage{
+			PromptTokens:     resp.Usage.InputTokens,
+			CompletionTokens: resp.Usage.OutputTokens,
+			TotalTokens:      resp.Usage.TotalTokens,
+		},
+		Truncated: resp.Status == "incomplete",
+	}, nil
+}
+
+// parseResponsesStreamEvent 將 Responses API 串流事件轉換為 StreamEvent
+func parseResponsesStreamEvent(provider, event string, data []byte) (StreamEvent, bool, error) {
+	switch {
+	case event == "response.output_text.delta":
+		var payload struct {
+			Delta string `json:"delta"`
+		}
+		if err := json.Unmarshal(data, &payload); err != nil {
+			return StreamEvent{}, false, err
+		}
+		return StreamEvent{Type: StreamEventDelta, Delta: payload.Delta}, true, nil
+
+	case strings.HasPrefix(event, "response.web_search_call."):
+		// in_progress、searching、completed
+		status := strings.TrimPrefix(event, "response.web_search_call.")
+		return StreamEvent{Type: StreamEventWebSearch, Status: status}, true, nil
+
+	case event == "response.completed", event == "response.incomplete":
+		// incomplete 表示回答在達到 max_output_tokens 等限制時被截斷，已輸出的內容仍然有效
+		var payload struct {
+			Response responsesResponse `json:"response"`
+		}
+		if err := json.Unmarshal(data, &payload); err != nil {
+			return StreamEvent{}, false, err
+		}
+		usage := payload.Response.Usage
+		return StreamEvent{
+			Type:       StreamEventDone,
+			ResponseID: payload.Response.ID,
+			Usage: models.TokenUsage{
+				PromptTokens:     usage.InputTokens,
+				CompletionTokens: usage.OutputTokens,
+				TotalTokens:      usage.TotalTokens,
+			},
+			Truncated: event == "response.incomplete",
+		}, true, nil
+
+	case event == "response.failed", event == "error":
+		var payload struct {
+			Message  string `json:"message"`
+			Response struct {
+				Error struct {
+					Message string `json:"message"`
+				} `json:"error"`
+			} `json:"response"`
+		}
+		_ = json.Unmarshal(data, &payload)
+
+		message := payload.Message
+		if message == "" {
+			message = payload.Response.Error.Message
+		}
+		if message == "" {
+			message = event
+		}
+		return StreamEvent{}, false, streamError(provider, message)
+	}
+
+	return StreamEvent{}, false, nil
+}
@@ -0,0 +1,118 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/rocker15962/llm-web-assistant/packages/backend/config"
+	"github.com/rocker15962/llm-web-assistant/packages/backend/models"
+)
+
+// OpenAIChatProvider 使用 OpenAI Chat Completions API (/v1/chat/completions)
+// 適用於只實作 chat/completions 的 OpenAI 相容伺服器
+type OpenAIChatProvider struct {
+	cfg ProviderConfig
+}
+
+// NewOpenAIChatProvider 建立 Chat Completions 提供者
+func NewOpenAIChatProvider(cfg ProviderConfig) (*OpenAIChatProvider, error) {
+	if cfg.APIKey == "" {
+		return nil, errMissingAPIKey(ProviderOpenAIChat, cfg)
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = config.GetLLMAPIURL()
+	}
+	return &OpenAIChatProvider{cfg: cfg}, nil
+}
+
+// Name 返回提供者名稱
+func (p *OpenAIChatProvider) Name() string {
+	return ProviderOpenAIChat
+}
+
+// Model 返回默認模型
+func (p *OpenAIChatProvider) Model() string {
+	return p.cfg.Model
+}
+
+// BuildRequest 構建 Chat Completions 請求
+func (p *OpenAIChatProvider) BuildRequest(ctx context.Context, prompt Prompt) (*http.Request, error) {
+	if prompt.UseWebSearch {
+		LogWarning("Chat Completions API 不支援 web_search_preview，已忽略網絡搜索")
+	}
+
+	httpReq, err := postJSON(ctx, p.cfg.Endpoint, buildChatRequest(prompt, p.cfg.Model))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+p.cfg.APIKey)
+	return httpReq, nil
+}
+
+// ParseResponse 解析 Chat Completions 響應
+func (p *OpenAIChatProvider) ParseResponse(body []byte) (ProviderResult, error) {
+	return parseChatResponse(body)
+}
+
+// buildChatRequest 將提示詞轉換為 Chat Completions 請求體
+func buildChatRequest(prompt Prompt, defaultModel string) models.OpenAIRequest {
+	model := prompt.Model
+	if model == "" {
+		model = defaultModel
+	}
+
+	messages := []models.OpenAIMessage{}
+	if prompt.System != "" {
+		messages = append(messages, models.OpenAIMessage{Role: "system", Content: prompt.System})
+	}
+
+	for _, msg := range prompt.Messages {
+		// 沒有圖片時使用純文字內容，相容性最好
+		if len(msg.Images) == 0 {
+			messages = append(messages, models.OpenAIMessage{Role: msg.Role, Content: msg.Text})
+			continue
+		}
+
+		parts := []models.OpenAIContentPart{{Type: "text", Text: msg.Text}}
+		for _, image := range msg.Images {
+			parts = append(parts, models.OpenAIContentPart{
+				Type:     "image_url",
+				ImageURL: &models.OpenAIImageURL{URL: image},
+			})
+		}
+		messages = append(messages, models.OpenAIMessage{Role: msg.Role, Content: parts})
+	}
+
+	return models.OpenAIRequest{
+		Model:       model,
+		Messages:    messages,
+		MaxTokens:   prompt.MaxOutputTokens,
+		Temperature: prompt.Temperature,
+	}
+}
+
+// parseChatResponse 從 Chat Completions 響應中提取回答和 token 使用量
+func parseChatResponse(body []byte) (ProviderResult, error) {
+	var resp models.OpenAIResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return ProviderResult{}, err
+	}
+
+	var answer string
+	var truncated bool
+	if len(resp.Choices) > 0 {
+		answer = resp.Choices[0].Message.Content
+		truncated = resp.Choices[0].FinishReason == "length"
+	}
+
+	return ProviderResult{
+		Answer:    answer,
+		Truncated: truncated,
+		Usage: models.TokenUsage{
+			PromptTokens:     resp.Usage.PromptTokens,
+			CompletionTokens: resp.Usage.CompletionTokens,
+			TotalTokens:      resp.Usage.TotalTokens,
+		},
+	}, nil
+}
@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"context"
+	"testing"
+)
+
+// chatCompletionResponse 是 Chat Completions 的成功響應
+const chatCompletionResponse = `{
+	"id": "chatcmpl-1",
+	"object": "chat.completion",
+	"choices": [{"index": 0, "message": {"role": "assistant", "content": "回答"}, "finish_reason": "stop"}],
+	"usage": {"prompt_tokens": 10, "completion_tokens": 5, "total_tokens": 15}
+}`
+
+func TestOpenAIChatRoundTrip(t *testing.T) {
+	server, captured := newCaptureServer(t, chatCompletionResponse)
+
+	provider, err := NewOpenAIChatProvider(ProviderConfig{APIKey: "sk-test", Endpoint: server.URL + "/v1/chat/completions", Model: "gpt-test"})
+	if err != nil {
+		t.Fatalf("NewOpenAIChatProvider: %v", err)
+	}
+
+	result, err := callProvider(context.Background(), provider, testPrompt())
+	if err != nil {
+		t.Fatalf("callProvider: %v", err)
+	}
+
+	want := ProviderResult{Answer: "回答", Usage: usage(10, 5, 15)}
+	if result != want {
+		t.Errorf("result = %+v, want %+v", result, want)
+	}
+
+	if captured.Path != "/v1/chat/completions" {
+		t.Errorf("path = %q, want /v1/chat/completions", captured.Path)
+	}
+	if got := captured.Header.Get("Authorization"); got != "Bearer sk-test" {
+		t.Errorf("Authorization = %q", got)
+	}
+	// 沒有圖片的消息使用純文字內容，有圖片時使用內容片段；Chat Completions 不支援網絡搜索工具
+	checkJSON(t, captured.Body, []jsonField{
+		{[]interface{}{"model"}, "gpt-test"},
+		{[]interface{}{"messages", 0, "role"}, "system"},
+		{[]interface{}{"messages", 0, "content"}, "系統提示"},
+		{[]interface{}{"messages", 1, "content"}, "第一題"},
+		{[]interface{}{"messages", 2, "role"}, "assistant"},
+		{[]interface{}{"messages", 3, "content", 0, "type"}, "text"},
+		{[]interface{}{"messages", 3, "content", 0, "text"}, "第二題"},
+		{[]interface{}{"messages", 3, "content", 1, "type"}, "image_url"},
+		{[]interface{}{"messages", 3, "content", 1, "image_url", "url"}, "data:image/png;base64,AAAA"},
+		{[]interface{}{"max_tokens"}, float64(100)},
+		{[]interface{}{"temperature"}, 0.7},
+		{[]interface{}{"tools"}, nil},
+	})
+}
+
+func TestOpenAIChatPromptModel(t *testing.T) {
+	server, captured := newCaptureServer(t, chatCompletionResponse)
+
+	provider, err := NewOpenAIChatProvider(ProviderConfig{APIKey: "sk-test", Endpoint: server.URL, Model: "gpt-test"})
+	if err != nil {
+		t.Fatalf("NewOpenAIChatProvider: %v", err)
+	}
+
+	// 提示詞指定的模型優先於提供者的默認模型
+	prompt := testPrompt()
+	prompt.Model = "gpt-other"
+	if _, err := callProvider(context.Background(), provider, prompt); err != nil {
+		t.Fatalf("callProvider: %v", err)
+	}
+	if got := captured.Body["model"]; got != "gpt-other" {
+		t.Errorf("model = %v, want gpt-other", got)
+	}
+}
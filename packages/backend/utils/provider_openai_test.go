@@ -0,0 +1,103 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOpenAIResponsesRoundTrip(t *testing.T) {
+	server, captured := newCaptureServer(t, `{
+		"id": "resp_1",
+		"status": "completed",
+		"output": [
+			{"type": "web_search_call"},
+			{"type": "message", "content": [{"type": "output_text", "text": "回答"}]}
+		],
+		"usage": {"input_tokens": 10, "output_tokens": 5, "total_tokens": 15}
+	}`)
+
+	provider, err := NewOpenAIResponsesProvider(ProviderConfig{APIKey: "sk-test", Endpoint: server.URL + "/v1/responses", Model: "gpt-test"})
+	if err != nil {
+		t.Fatalf("NewOpenAIResponsesProvider: %v", err)
+	}
+
+	prompt := testPrompt()
+	prompt.PreviousResponseID = "resp_0"
+	result, err := callProvider(context.Background(), provider, prompt)
+	if err != nil {
+		t.Fatalf("callProvider: %v", err)
+	}
+
+	want := ProviderResult{Answer: "回答", ResponseID: "resp_1", Usage: usage(10, 5, 15)}
+	if result != want {
+		t.Errorf("result = %+v, want %+v", result, want)
+	}
+
+	if captured.Method != http.MethodPost || captured.Path != "/v1/responses" {
+		t.Errorf("request = %s %s, want POST /v1/responses", captured.Method, captured.Path)
+	}
+	if got := captured.Header.Get("Authorization"); got != "Bearer sk-test" {
+		t.Errorf("Authorization = %q", got)
+	}
+	checkJSON(t, captured.Body, []jsonField{
+		{[]interface{}{"model"}, "gpt-test"},
+		{[]interface{}{"input", 0, "role"}, "system"},
+		{[]interface{}{"input", 0, "content", 0, "text"}, "系統提示"},
+		{[]interface{}{"input", 1, "content", 0, "type"}, "input_text"},
+		{[]interface{}{"input", 2, "role"}, "assistant"},
+		{[]interface{}{"input", 2, "content", 0, "type"}, "output_text"},
+		{[]interface{}{"input", 3, "content", 0, "text"}, "第二題"},
+		{[]interface{}{"input", 3, "content", 1, "type"}, "input_image"},
+		{[]interface{}{"input", 3, "content", 1, "image_url"}, "data:image/png;base64,AAAA"},
+		{[]interface{}{"max_output_tokens"}, float64(100)},
+		{[]interface{}{"temperature"}, 0.7},
+		{[]interface{}{"tools", 0, "type"}, "web_search_preview"},
+		{[]interface{}{"previous_response_id"}, "resp_0"},
+		{[]interface{}{"stream"}, nil},
+	})
+}
+
+func TestCallProviderAPIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte(`{"error": {"message": "Rate limit reached", "type": "requests", "code": "rate_limit_exceeded"}}`))
+	}))
+	defer server.Close()
+
+	provider, err := NewOpenAIResponsesProvider(ProviderConfig{APIKey: "sk-test", Endpoint: server.URL})
+	if err != nil {
+		t.Fatalf("NewOpenAIResponsesProvider: %v", err)
+	}
+
+	_, err = callProvider(context.Background(), provider, testPrompt())
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("err = %v, want *APIError", err)
+	}
+	want := APIError{Provider: ProviderOpenAI, StatusCode: 429, Message: "Rate limit reached", Type: "requests", Code: "rate_limit_exceeded"}
+	if *apiErr != want {
+		t.Errorf("APIError = %+v, want %+v", *apiErr, want)
+	}
+}
+
+func TestCallProviderEmptyAnswer(t *testing.T) {
+	server, _ := newCaptureServer(t, `{"id": "resp_1", "status": "completed", "output": []}`)
+
+	provider, err := NewOpenAIResponsesProvider(ProviderConfig{APIKey: "sk-test", Endpoint: server.URL})
+	if err != nil {
+		t.Fatalf("NewOpenAIResponsesProvider: %v", err)
+	}
+
+	if _, err := callProvider(context.Background(), provider, testPrompt()); !errors.Is(err, ErrEmptyAnswer) {
+		t.Errorf("err = %v, want ErrEmptyAnswer", err)
+	}
+}
+
+func TestNewOpenAIResponsesProviderMissingKey(t *testing.T) {
+	if _, err := NewOpenAIResponsesProvider(ProviderConfig{}); err == nil {
+		t.Error("expected an error without an API key")
+	}
+}
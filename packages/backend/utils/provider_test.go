@@ -0,0 +1,129 @@
+package utils
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/rocker15962/llm-web-assistant/packages/backend/models"
+)
+
+// usage 構建 token 使用量
+func usage(prompt, completion, total int) models.TokenUsage {
+	return models.TokenUsage{PromptTokens: prompt, CompletionTokens: completion, TotalTokens: total}
+}
+
+func TestParseResponseTruncated(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider Provider
+		body     string
+		want     bool
+	}{
+		{"responses completed", &OpenAIResponsesProvider{}, `{"status":"completed"}`, false},
+		{"responses incomplete", &OpenAIResponsesProvider{}, `{"status":"incomplete"}`, true},
+		{"chat stop", &OpenAIChatProvider{}, `{"choices":[{"finish_reason":"stop"}]}`, false},
+		{"chat length", &OpenAIChatProvider{}, `{"choices":[{"finish_reason":"length"}]}`, true},
+		{"llamacpp length", &LlamaCppProvider{}, `{"choices":[{"finish_reason":"length"}]}`, true},
+		{"anthropic end turn", &AnthropicProvider{}, `{"stop_reason":"end_turn"}`, false},
+		{"anthropic max tokens", &AnthropicProvider{}, `{"stop_reason":"max_tokens"}`, true},
+		{"gemini stop", &GeminiProvider{}, `{"candidates":[{"finishReason":"STOP"}]}`, false},
+		{"gemini max tokens", &GeminiProvider{}, `{"candidates":[{"finishReason":"MAX_TOKENS"}]}`, true},
+		{"ollama stop", &OllamaProvider{}, `{"done_reason":"stop"}`, false},
+		{"ollama length", &OllamaProvider{}, `{"done_reason":"length"}`, true},
+	}
+
+	for _, tt := range tests {
+		result, err := tt.provider.ParseResponse([]byte(tt.body))
+		if err != nil {
+			t.Errorf("%s: ParseResponse: %v", tt.name, err)
+			continue
+		}
+		if result.Truncated != tt.want {
+			t.Errorf("%s: Truncated = %v, want %v", tt.name, result.Truncated, tt.want)
+		}
+	}
+}
+
+// capturedRequest 記錄測試伺服器收到的請求
+type capturedRequest struct {
+	Method string
+	Path   string
+	Query  url.Values
+	Header http.Header
+	Body   map[string]interface{}
+}
+
+// newCaptureServer 返回記錄請求並以 response 回應的測試伺服器
+func newCaptureServer(t *testing.T, response string) (*httptest.Server, *capturedRequest) {
+	t.Helper()
+	captured := &capturedRequest{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		captured.Method = r.Method
+		captured.Path = r.URL.Path
+		captured.Query = r.URL.Query()
+		captured.Header = r.Header.Clone()
+		if err := json.NewDecoder(r.Body).Decode(&captured.Body); err != nil {
+			t.Errorf("decode request body: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(response))
+	}))
+	t.Cleanup(server.Close)
+	return server, captured
+}
+
+// testPrompt 返回帶有系統提示詞、歷史和截圖的提示詞
+func testPrompt() Prompt {
+	return Prompt{
+		System: "系統提示",
+		Messages: []PromptMessage{
+			{Role: "user", Text: "第一題"},
+			{Role: "assistant", Text: "第一個回答"},
+			{Role: "user", Text: "第二題", Images: []string{"data:image/png;base64,AAAA"}},
+		},
+		MaxOutputTokens: 100,
+		Temperature:     0.7,
+		UseWebSearch:    true,
+	}
+}
+
+// jsonPath 按鍵和索引取出解碼後 JSON 中的值，路徑不存在時返回 nil
+func jsonPath(v interface{}, path ...interface{}) interface{} {
+	for _, key := range path {
+		switch k := key.(type) {
+		case string:
+			m, ok := v.(map[string]interface{})
+			if !ok {
+				return nil
+			}
+			v = m[k]
+		case int:
+			a, ok := v.([]interface{})
+			if !ok || k >= len(a) {
+				return nil
+			}
+			v = a[k]
+		}
+	}
+	return v
+}
+
+// jsonField 是請求體中一個路徑的期望值
+type jsonField struct {
+	path []interface{}
+	want interface{}
+}
+
+// checkJSON 檢查解碼後 JSON 中各路徑的值，數字以 float64 比較
+func checkJSON(t *testing.T, body map[string]interface{}, fields []jsonField) {
+	t.Helper()
+	for _, field := range fields {
+		if got := jsonPath(body, field.path...); !reflect.DeepEqual(got, field.want) {
+			t.Errorf("request %v = %#v, want %#v", field.path, got, field.want)
+		}
+	}
+}
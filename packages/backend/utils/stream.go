@@ -0,0 +1,199 @@
+package utils
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rocker15962/llm-web-assistant/packages/backend/models"
+)
+
+// 串流事件類型
+const (
+	StreamEventDelta     = "delta"      // 回答文字片段
+	StreamEventWebSearch = "web_search" // 網絡搜索進度
+	StreamEventDone      = "done"       // 生成完成，帶有 token 使用量
+)
+
+// StreamEvent 表示串流生成過程中的事件
+type StreamEvent struct {
+	Type   string            `json:"-"`
+	Delta  string            `json:"delta,omitempty"`
+	Status string            `json:"status,omitempty"`
+	Usage  models.TokenUsage `json:"-"`
+	// ResponseID 只在 done 事件中帶有上游回應的 ID
+	ResponseID string `json:"-"`
+	// Truncated 只在 done 事件中標示回答是否被截斷
+	Truncated bool `json:"-"`
+}
+
+// StreamProvider 由支援串流輸出的提供者實現
+type StreamProvider interface {
+	Provider
+	// BuildStreamRequest 構建串流請求
+	BuildStreamRequest(ctx context.Context, prompt Prompt) (*http.Request, error)
+	// ParseStreamEvent 將一個 SSE 事件轉換為串流事件，ok 為 false 表示忽略該事件
+	ParseStreamEvent(event string, data []byte) (streamEvent StreamEvent, ok bool, err error)
+}
+
+// StreamResponse 以串流方式生成回應，每個事件都會傳給 onEvent
+// onEvent 返回錯誤（例如客戶端斷線）時會中止生成
+func StreamResponse(ctx context.Context, req models.AskRequest, onEvent func(StreamEvent) error) (models.AskResponse, error) {
+	startTime := time.Now()
+
+	response, err := generate(ctx, req, streamCall(onEvent))
+	if err != nil {
+		return models.AskResponse{}, err
+	}
+
+	LogDebug("LLM 串流完成，耗時: %v", time.Since(startTime))
+
+	return response, nil
+}
+
+// streamCall 返回以串流方式呼叫提供者的 providerCall
+func streamCall(onEvent func(StreamEvent) error) providerCall {
+	return func(ctx context.Context, provider Provider, prompt Prompt) (ProviderResult, error) {
+		streamer, ok := provider.(StreamProvider)
+		if ok {
+			return callStreamProvider(ctx, streamer, prompt, onEvent)
+		}
+
+		// 不支援串流的提供者：完整生成後一次輸出
+		result, err := callProvider(ctx, provider, prompt)
+		if err != nil {
+			return ProviderResult{}, err
+		}
+		if err := onEvent(StreamEvent{Type: StreamEventDelta, Delta: result.Answer}); err != nil {
+			return ProviderResult{}, &errPartialStream{err: err}
+		}
+		return result, nil
+	}
+}
+
+// callStreamProvider 發送串流請求並逐一轉發事件
+func callStreamProvider(ctx context.Context, provider StreamProvider, prompt Prompt, onEvent func(StreamEvent) error) (ProviderResult, error) {
+	httpReq, err := provider.BuildStreamRequest(ctx, prompt)
+	if err != nil {
+		LogErrorDetails(err, "創建 HTTP 請求失敗")
+		return ProviderResult{}, err
+	}
+
+	resp, err := sendProviderRequest(provider, httpReq)
+	if err != nil {
+		return ProviderResult{}, err
+	}
+	defer resp.Body.Close()
+
+	var (
+		answer   strings.Builder
+		result   ProviderResult
+		emitted  bool
+		finished bool
+	)
+
+	// fail 在已經輸出內容後包裝錯誤，避免切換提供者造成重複內容
+	fail := func(err error) (ProviderResult, error) {
+		if emitted {
+			return ProviderResult{}, &errPartialStream{err: err}
+		}
+		return ProviderResult{}, err
+	}
+
+	err = readSSE(resp.Body, func(event string, data []byte) error {
+		// 每收到一個事件就重新計算超時，長但持續輸出的串流不會被中止
+		touchIdleTimeout(ctx)
+
+		streamEvent, ok, err := provider.ParseStreamEvent(event, data)
+		if err != nil || !ok {
+			return err
+		}
+
+		switch streamEvent.Type {
+		case StreamEventDelta:
+			answer.WriteString(streamEvent.Delta)
+		case StreamEventDone:
+			result.Usage = streamEvent.Usage
+			result.ResponseID = streamEvent.ResponseID
+			result.Truncated = streamEvent.Truncated
+			finished = true
+		}
+
+		if err := onEvent(streamEvent); err != nil {
+			return err
+		}
+		emitted = emitted || streamEvent.Type == StreamEventDelta
+		return nil
+	})
+	if err != nil {
+		LogErrorDetails(err, "讀取 LLM 串流失敗")
+		return fail(err)
+	}
+
+	if answer.Len() == 0 {
+		return ProviderResult{}, ErrEmptyAnswer
+	}
+	if !finished {
+		return fail(errors.New("LLM 串流意外結束"))
+	}
+
+	result.Answer = answer.String()
+	return result, nil
+}
+
+// readSSE 逐一讀取 Server-Sent Events，對每個事件呼叫 handle
+func readSSE(body io.Reader, handle func(event string, data []byte) error) error {
+	reader := bufio.NewReader(body)
+
+	var (
+		event string
+		data  strings.Builder
+	)
+
+	dispatch := func() error {
+		defer func() {
+			event = ""
+			data.Reset()
+		}()
+		if data.Len() == 0 {
+			return nil
+		}
+		return handle(event, []byte(data.String()))
+	}
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		switch {
+		case line == "":
+			if dispatchErr := dispatch(); dispatchErr != nil {
+				return dispatchErr
+			}
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+
+		if err == io.EOF {
+			return dispatch()
+		}
+	}
+}
+
+// streamError 表示串流中提供者返回的錯誤事件
+func streamError(provider, message string) error {
+	return fmt.Errorf("%s 串流錯誤: %s", provider, message)
+}
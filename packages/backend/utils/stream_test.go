@@ -0,0 +1,192 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadSSE(t *testing.T) {
+	type sseEvent struct {
+		event string
+		data  string
+	}
+
+	body := strings.Join([]string{
+		": 心跳註解",
+		"event: response.output_text.delta",
+		`data: {"delta":"你好"}`,
+		"",
+		"data: 第一行",
+		"data: 第二行",
+		"",
+		"event: 沒有資料的事件",
+		"",
+		"event: done\r",
+		"data:[DONE]\r",
+		"\r",
+		"event: last",
+		"data: 沒有結尾空行",
+	}, "\n")
+
+	var got []sseEvent
+	err := readSSE(strings.NewReader(body), func(event string, data []byte) error {
+		got = append(got, sseEvent{event, string(data)})
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("readSSE: %v", err)
+	}
+
+	want := []sseEvent{
+		{"response.output_text.delta", `{"delta":"你好"}`},
+		{"", "第一行\n第二行"},
+		{"done", "[DONE]"},
+		{"last", "沒有結尾空行"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("events = %q, want %q", got, want)
+	}
+}
+
+func TestReadSSEStopsOnHandlerError(t *testing.T) {
+	stop := errors.New("停止")
+	calls := 0
+	err := readSSE(strings.NewReader("data: a\n\ndata: b\n\n"), func(string, []byte) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("err = %v, calls = %d, want %v after 1 call", err, calls, stop)
+	}
+}
+
+func TestParseResponsesStreamEvent(t *testing.T) {
+	tests := []struct {
+		event   string
+		data    string
+		want    StreamEvent
+		wantOK  bool
+		wantErr bool
+	}{
+		{"response.output_text.delta", `{"delta":"片段"}`, StreamEvent{Type: StreamEventDelta, Delta: "片段"}, true, false},
+		{"response.web_search_call.searching", `{}`, StreamEvent{Type: StreamEventWebSearch, Status: "searching"}, true, false},
+		{
+			"response.completed",
+			`{"response":{"id":"resp_1","usage":{"input_tokens":3,"output_tokens":4,"total_tokens":7}}}`,
+			StreamEvent{Type: StreamEventDone, ResponseID: "resp_1", Usage: usage(3, 4, 7)},
+			true, false,
+		},
+		{
+			"response.incomplete",
+			`{"response":{"id":"resp_2","usage":{"input_tokens":1,"output_tokens":2,"total_tokens":3}}}`,
+			StreamEvent{Type: StreamEventDone, ResponseID: "resp_2", Usage: usage(1, 2, 3), Truncated: true},
+			true, false,
+		},
+		{"response.failed", `{"response":{"error":{"message":"壞了"}}}`, StreamEvent{}, false, true},
+		{"error", `{"message":"壞了"}`, StreamEvent{}, false, true},
+		{"response.created", `{}`, StreamEvent{}, false, false},
+	}
+
+	for _, tt := range tests {
+		got, ok, err := parseResponsesStreamEvent(ProviderOpenAI, tt.event, []byte(tt.data))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, wantErr %v", tt.event, err, tt.wantErr)
+		}
+		if ok != tt.wantOK || got != tt.want {
+			t.Errorf("%s: got %+v, %v, want %+v, %v", tt.event, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+// newSSEProvider 返回串流到測試伺服器的 Responses API 提供者，伺服器依序輸出 events
+func newSSEProvider(t *testing.T, events ...string) *OpenAIResponsesProvider {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range events {
+			_, _ = w.Write([]byte(event + "\n\n"))
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	provider, err := NewOpenAIResponsesProvider(ProviderConfig{APIKey: "test", Endpoint: server.URL, Model: "gpt-test"})
+	if err != nil {
+		t.Fatalf("NewOpenAIResponsesProvider: %v", err)
+	}
+	return provider
+}
+
+func TestCallStreamProvider(t *testing.T) {
+	provider := newSSEProvider(t,
+		"event: response.output_text.delta\ndata: {\"delta\":\"你\"}",
+		"event: response.web_search_call.completed\ndata: {}",
+		"event: response.output_text.delta\ndata: {\"delta\":\"好\"}",
+		"event: response.incomplete\ndata: {\"response\":{\"id\":\"resp_1\",\"usage\":{\"input_tokens\":5,\"output_tokens\":2,\"total_tokens\":7}}}",
+	)
+
+	var types []string
+	result, err := callStreamProvider(context.Background(), provider, Prompt{}, func(event StreamEvent) error {
+		types = append(types, event.Type)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("callStreamProvider: %v", err)
+	}
+
+	want := ProviderResult{Answer: "你好", Usage: usage(5, 2, 7), ResponseID: "resp_1", Truncated: true}
+	if result != want {
+		t.Errorf("result = %+v, want %+v", result, want)
+	}
+	wantTypes := []string{StreamEventDelta, StreamEventWebSearch, StreamEventDelta, StreamEventDone}
+	if !reflect.DeepEqual(types, wantTypes) {
+		t.Errorf("event types = %v, want %v", types, wantTypes)
+	}
+}
+
+func TestCallStreamProviderErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		events      []string
+		wantPartial bool
+	}{
+		{"error before output", []string{"event: error\ndata: {\"message\":\"壞了\"}"}, false},
+		{"error after output", []string{
+			"event: response.output_text.delta\ndata: {\"delta\":\"你\"}",
+			"event: error\ndata: {\"message\":\"壞了\"}",
+		}, true},
+		{"ended without done", []string{"event: response.output_text.delta\ndata: {\"delta\":\"你\"}"}, true},
+	}
+
+	for _, tt := range tests {
+		provider := newSSEProvider(t, tt.events...)
+		_, err := callStreamProvider(context.Background(), provider, Prompt{}, func(StreamEvent) error { return nil })
+		if err == nil {
+			t.Errorf("%s: expected error", tt.name)
+			continue
+		}
+		var partial *errPartialStream
+		if errors.As(err, &partial) != tt.wantPartial {
+			t.Errorf("%s: err = %v, partial %v", tt.name, err, tt.wantPartial)
+		}
+	}
+}
+
+func TestCallStreamProviderEmpty(t *testing.T) {
+	provider := newSSEProvider(t, "event: response.completed\ndata: {\"response\":{}}")
+	_, err := callStreamProvider(context.Background(), provider, Prompt{}, func(StreamEvent) error { return nil })
+	if !errors.Is(err, ErrEmptyAnswer) {
+		t.Errorf("err = %v, want ErrEmptyAnswer", err)
+	}
+}
+
+func TestErrPartialStreamUnwrap(t *testing.T) {
+	err := &errPartialStream{err: context.Canceled}
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("errors.Is(%v, context.Canceled) = false", err)
+	}
+}
@@ -0,0 +1,39 @@
+package utils
+
+import (
+	"github.com/rocker15962/llm-web-assistant/packages/backend/search"
+)
+
+// EstimateTokens 以字元類別估計文本的 token 數，不是任何分詞器的實際計數
+// CJK 字元大約每字 1 個 token，其他文字大約每 4 個字元 1 個 token，
+// 與 OpenAI 系列分詞器（cl100k_base、o200k_base）的比例相近；
+// 頁面上下文、歷史等預算都以此估計值為單位，換算為各模型的 token 數時
+// 乘以 tokenScaleFor 的提供者係數，並在取得上游回報的用量後改用實測的校準係數
+func EstimateTokens(text string) int {
+	cjk, other := 0, 0
+	for _, r := range text {
+		if search.IsCJK(r) {
+			cjk++
+		} else {
+			other++
+		}
+	}
+	return cjk + (other+3)/4
+}
+
+// providerTokenScales 各提供者分詞器相對於 EstimateTokens 的大致比例（以中英混合的網頁內容估計的經驗值）
+// 未列出的提供者（OpenAI、Azure OpenAI）使用 1
+var providerTokenScales = map[string]float64{
+	ProviderAnthropic: 1.2, // Claude 的詞表對中文較不緊湊
+	ProviderGemini:    0.9, // SentencePiece 詞表，中文多字可合為一個 token
+	ProviderOllama:    1.3, // Llama 等開源模型的中文 token 數通常較多
+	ProviderLlamaCpp:  1.3,
+}
+
+// tokenScaleFor 返回提供者相對於 EstimateTokens 的 token 比例，作為尚未實測時的校準係數
+func tokenScaleFor(providerName string) float64 {
+	if scale, ok := providerTokenScales[providerName]; ok {
+		return scale
+	}
+	return 1
+}
@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"testing"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"abcd", 1},
+		{"abcde", 2},
+		{"你好世界", 4},
+		{"Go 語言", 3}, // 2 個 CJK 字元加上 3 個其他字元
+	}
+
+	for _, tt := range tests {
+		if got := EstimateTokens(tt.text); got != tt.want {
+			t.Errorf("EstimateTokens(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestTokenScaleFor(t *testing.T) {
+	tests := []struct {
+		provider string
+		want     float64
+	}{
+		{ProviderOpenAI, 1},
+		{ProviderAnthropic, 1.2},
+		{ProviderGemini, 0.9},
+		{ProviderOllama, 1.3},
+		{"unknown", 1},
+	}
+
+	for _, tt := range tests {
+		if got := tokenScaleFor(tt.provider); got != tt.want {
+			t.Errorf("tokenScaleFor(%q) = %v, want %v", tt.provider, got, tt.want)
+		}
+	}
+}
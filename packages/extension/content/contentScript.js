@@ -94,6 +94,9 @@ async function handleGetPageInfo(mode) {
       url: url
     };
     
+    // 獲取使用者選取的文字及其所在段落
+    Object.assign(response, extractSelection());
+    
     // 根據模式獲取不同的數據
     if (mode === 'content' || mode === 'both') {
       console.log('正在提取頁面內容...');
@@ -129,13 +132,40 @@ function extractPageContent() {
   try {
     // 獲取頁面主要內容
     const headings = Array.from(document.querySelectorAll('h1, h2, h3, h4, h5, h6'))
-      .map(h => h.textContent.trim())
-      .filter(text => text.length > 0);
+      .map(h => ({
+        level: Number(h.tagName.substring(1)),
+        text: h.textContent.trim()
+      }))
+      .filter(heading => heading.text.length > 0);
     
     const paragraphs = Array.from(document.querySelectorAll('p'))
       .map(p => p.textContent.trim())
       .filter(text => text.length > 0);
     
+    const lists = Array.from(document.querySelectorAll('ul, ol'))
+      .map(list => ({
+        ordered: list.tagName === 'OL',
+        items: Array.from(list.children)
+          .filter(li => li.tagName === 'LI')
+          .map(li => li.textContent.trim())
+          .filter(text => text.length > 0)
+      }))
+      .filter(list => list.items.length > 0);
+    
+    const tables = Array.from(document.querySelectorAll('table'))
+      .map(table => {
+        const rows = Array.from(table.rows)
+          .map(row => Array.from(row.cells).map(cell => cell.textContent.trim()));
+        const hasHeader = table.rows.length > 0 &&
+          Array.from(table.rows[0].cells).every(cell => cell.tagName === 'TH');
+        return {
+          caption: table.caption ? table.caption.textContent.trim() : '',
+          headers: hasHeader ? rows[0] : [],
+          rows: hasHeader ? rows.slice(1) : rows
+        };
+      })
+      .filter(table => table.headers.length > 0 || table.rows.length > 0);
+    
     const links = Array.from(document.querySelectorAll('a[href]'))
       .map(a => ({
         text: a.textContent.trim(),
@@ -143,12 +173,26 @@ function extractPageContent() {
       }))
       .filter(link => link.text.length > 0);
     
+    const meta = {};
+    document.querySelectorAll('meta[name], meta[property]').forEach(tag => {
+      const name = tag.getAttribute('name') || tag.getAttribute('property');
+      const content = (tag.getAttribute('content') || '').trim();
+      if (/^(description|keywords|author|og:title|og:description|og:site_name|article:published_time)$/i.test(name) && content) {
+        meta[name.toLowerCase()] = content;
+      }
+    });
+    
     const bodyText = document.body.textContent.trim();
     
-    // 構建內容對象
+    // 構建內容對象，結構與後端的 models.PageContent 對應
     const contentObj = {
+      version: 2,
+      language: document.documentElement.lang || '',
+      meta,
       headings,
       paragraphs,
+      lists,
+      tables,
       links,
       bodyText: bodyText.substring(0, 10000) // 限制長度
     };
@@ -160,6 +204,28 @@ function extractPageContent() {
   }
 }
 
+// 提取使用者選取的文字和所在段落
+function extractSelection() {
+  const selection = window.getSelection();
+  const selectedText = selection.toString().trim();
+  if (!selectedText || selection.rangeCount === 0) {
+    return { selectedText: '', selectionContext: '' };
+  }
+  
+  // 向上找到包含選取範圍的區塊元素作為上下文
+  let node = selection.getRangeAt(0).commonAncestorContainer;
+  if (node.nodeType !== Node.ELEMENT_NODE) {
+    node = node.parentElement;
+  }
+  const block = node && node.closest('p, li, td, th, blockquote, pre, h1, h2, h3, h4, h5, h6, article, section, div');
+  const selectionContext = block ? block.textContent.trim().substring(0, 2000) : '';
+  
+  return {
+    selectedText: selectedText.substring(0, 5000), // 限制長度
+    selectionContext: selectionContext === selectedText ? '' : selectionContext
+  };
+}
+
 // 獲取頁面截圖
 async function captureVisibleTab() {
   try {
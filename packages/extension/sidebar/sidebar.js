@@ -183,6 +183,8 @@ document.addEventListener('DOMContentLoaded', function() {
         title: currentPageInfo.title,
         screenshot: currentPageInfo.screenshot,
         pageContent: currentPageInfo.pageContent,
+        selectedText: currentPageInfo.selectedText,
+        selectionContext: currentPageInfo.selectionContext,
         useWebSearch: useWebSearch,
         isSimple: currentIsSimple
       });
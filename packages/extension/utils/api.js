@@ -20,6 +20,8 @@ const ENDPOINTS = {
  * @param {string} data.title - 當前頁面標題
  * @param {string|null} data.screenshot - 頁面截圖 (base64)
  * @param {string|null} data.pageContent - 頁面內容
+ * @param {string} [data.selectedText] - 使用者選取的文字
+ * @param {string} [data.selectionContext] - 選取文字所在的段落
  * @param {boolean} data.useWebSearch - 是否使用網絡搜索
  * @param {boolean} data.isSimple - 是否使用簡單回答模式
  * @returns {Promise<Object>} - LLM 回應
@@ -64,6 +66,8 @@ async function askLLM(data) {
         title: data.title,
         screenshot: data.screenshot || null,
         pageContent: data.pageContent || null,
+        selectedText: data.selectedText || '',
+        selectionContext: data.selectionContext || '',
         useWebSearch: data.useWebSearch || false,
         isSimple: data.isSimple || false
       })
@@ -157,7 +161,7 @@ function simulateResponse(data) {
       answer += `\n\n根據網頁內容，這似乎是一個${contentObj.paragraphs.length > 10 ? '內容豐富的' : '簡單的'}頁面。`;
       
       if (contentObj.headings.length > 0) {
-        answer += `\n\n頁面包含以下主要標題：\n- ${contentObj.headings.slice(0, 3).join('\n- ')}`;
+        answer += `\n\n頁面包含以下主要標題：\n- ${contentObj.headings.slice(0, 3).map(h => h.text || h).join('\n- ')}`;
         if (contentObj.headings.length > 3) {
           answer += `\n- ...等${contentObj.headings.length}個標題`;
         }